@@ -9,8 +9,42 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Span is the part of the source the node was parsed from
+	Span() Span
+	SetSpan(span Span)
 }
 
+// Position is a 1-based line and column in the source
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Span runs from the first character of a node's first token to just past the last character of
+// its last token, parentheses around an expression excluded
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// TokenSpan is the span of tok alone
+func TokenSpan(tok token.Token) Span {
+	return Span{
+		Start: Position{Line: tok.Line, Column: tok.Column},
+		End:   Position{Line: tok.EndLine, Column: tok.EndColumn},
+	}
+}
+
+// Spanned is embedded in every node to hold its span. The parser sets it; nodes built any other way
+// have the zero Span unless they set one.
+type Spanned struct {
+	span Span
+}
+
+func (s *Spanned) Span() Span { return s.span }
+
+func (s *Spanned) SetSpan(span Span) { s.span = span }
+
 type Statement interface {
 	Node
 	statementNode()
@@ -22,6 +56,7 @@ type Expression interface {
 }
 
 type Program struct {
+	Spanned
 	Statements []Statement
 }
 
@@ -45,6 +80,7 @@ func (p *Program) String() string {
 
 // Statements
 type LetStatement struct {
+	Spanned
 	Token token.Token // the token.LET token
 	Name  *Identifier
 	Value Expression
@@ -68,7 +104,43 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// EnumStatement declares an enum: enum Color { Red, Green, Blue }
+type EnumStatement struct {
+	Spanned
+	Token   token.Token // the token.ENUM token
+	Name    *Identifier
+	Members []*Identifier
+}
+
+func (es *EnumStatement) statementNode()       {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) String() string {
+	members := []string{}
+	for _, m := range es.Members {
+		members = append(members, m.String())
+	}
+
+	return es.TokenLiteral() + " " + es.Name.String() + " { " + strings.Join(members, ", ") + " }"
+}
+
+// GuardStatement lets the rest of the function run only when its condition is truthy. Otherwise
+// it runs its else block and returns from the function with the block's value, or with the value
+// of a return inside it.
+type GuardStatement struct {
+	Spanned
+	Token       token.Token // the 'guard' token
+	Condition   Expression
+	Alternative *BlockStatement
+}
+
+func (gs *GuardStatement) statementNode()       {}
+func (gs *GuardStatement) TokenLiteral() string { return gs.Token.Literal }
+func (gs *GuardStatement) String() string {
+	return "guard" + gs.Condition.String() + " else " + gs.Alternative.String() + ";"
+}
+
 type ReturnStatement struct {
+	Spanned
 	Token       token.Token // the token.RETURN token
 	ReturnValue Expression
 }
@@ -78,9 +150,9 @@ func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
-	out.WriteString(rs.TokenLiteral() + " ")
+	out.WriteString(rs.TokenLiteral())
 	if rs.ReturnValue != nil {
-		out.WriteString(rs.ReturnValue.String())
+		out.WriteString(" " + rs.ReturnValue.String())
 	}
 
 	out.WriteString(";")
@@ -89,6 +161,7 @@ func (rs *ReturnStatement) String() string {
 }
 
 type ExpressionStatement struct {
+	Spanned
 	Token      token.Token // the first token of the expression
 	Expression Expression
 }
@@ -117,6 +190,7 @@ func (bs *BlockStatement) String() string {
 
 // Expressions
 type Identifier struct {
+	Spanned
 	Token token.Token // the token.IDENT token
 	Value string
 }
@@ -125,7 +199,12 @@ func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
 
+// IsBlank reports whether the identifier is _, which can be bound any number of times to values
+// that are thrown away, and can't be used as a value itself
+func (i *Identifier) IsBlank() bool { return i.Value == "_" }
+
 type IntegerLiteral struct {
+	Spanned
 	Token token.Token
 	Value int64 // Not string!
 }
@@ -134,7 +213,18 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Spanned
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type StringLiteral struct {
+	Spanned
 	Token token.Token
 	Value string
 }
@@ -144,6 +234,7 @@ func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
 type PrefixExpression struct {
+	Spanned
 	Token    token.Token // the prefix token, e.g. !
 	Operator string
 	Right    Expression
@@ -163,6 +254,7 @@ func (pe *PrefixExpression) String() string {
 }
 
 type InfixExpression struct {
+	Spanned
 	Token    token.Token // the operator token, e.g. +
 	Left     Expression
 	Operator string
@@ -184,6 +276,7 @@ func (ie *InfixExpression) String() string {
 }
 
 type Boolean struct {
+	Spanned
 	Token token.Token
 	Value bool
 }
@@ -192,13 +285,42 @@ func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+type NullLiteral struct {
+	Spanned
+	Token token.Token // the token.NULL token
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) String() string       { return nl.Token.Literal }
+
+// IfExpression is a conditional. An `else if` is parsed into an Alternative holding nothing but
+// the chained IfExpression, with the chained 'if' as the block's token; see ElseIf.
 type IfExpression struct {
+	Spanned
 	Token       token.Token // the 'if' token
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
 }
 
+// ElseIf returns the IfExpression chained to this one by `else if`, or nil when there's none.
+// An `else { if ... }` written out with braces doesn't count as a chain.
+func (ie *IfExpression) ElseIf() *IfExpression {
+	if ie.Alternative == nil || ie.Alternative.Token.Type != token.IF ||
+		len(ie.Alternative.Statements) != 1 {
+		return nil
+	}
+
+	stmt, ok := ie.Alternative.Statements[0].(*ExpressionStatement)
+	if !ok {
+		return nil
+	}
+
+	elseIf, _ := stmt.Expression.(*IfExpression)
+	return elseIf
+}
+
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IfExpression) String() string {
@@ -209,7 +331,10 @@ func (ie *IfExpression) String() string {
 	out.WriteString(" ")
 	out.WriteString(ie.Consequence.String())
 
-	if ie.Alternative != nil {
+	if elseIf := ie.ElseIf(); elseIf != nil {
+		out.WriteString("else ")
+		out.WriteString(elseIf.String())
+	} else if ie.Alternative != nil {
 		out.WriteString("else ")
 		out.WriteString(ie.Alternative.String())
 	}
@@ -217,15 +342,93 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// ReturnExpression is a return used as an expression, like the one in `arg || return 0`. It ends
+// the enclosing function the way a ReturnStatement does, so it has no value of its own.
+type ReturnExpression struct {
+	Spanned
+	Token       token.Token // the 'return' token
+	ReturnValue Expression  // nil for a bare return
+}
+
+func (re *ReturnExpression) expressionNode()      {}
+func (re *ReturnExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *ReturnExpression) String() string {
+	if re.ReturnValue == nil {
+		return re.TokenLiteral()
+	}
+
+	return re.TokenLiteral() + " " + re.ReturnValue.String()
+}
+
+// DoExpression runs its block in a scope of its own and evaluates to the block's value. A return
+// inside it leaves the enclosing function, as it would outside the block.
+type DoExpression struct {
+	Spanned
+	Token token.Token // the 'do' token
+	Body  *BlockStatement
+}
+
+func (de *DoExpression) expressionNode()      {}
+func (de *DoExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DoExpression) String() string {
+	return "do " + de.Body.String()
+}
+
+// WithExpression binds each name to its value in a scope of its own, in order so that a value can
+// use the names bound before it, and evaluates to the value of its block in that scope. Like a do
+// block it doesn't catch returns, which leave the enclosing function.
+type WithExpression struct {
+	Spanned
+	Token  token.Token // the 'with' token
+	Names  []*Identifier
+	Values []Expression
+	Body   *BlockStatement
+}
+
+func (we *WithExpression) expressionNode()      {}
+func (we *WithExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WithExpression) String() string {
+	var out bytes.Buffer
+
+	bindings := []string{}
+	for i, name := range we.Names {
+		bindings = append(bindings, name.String()+" = "+we.Values[i].String())
+	}
+
+	out.WriteString("with (")
+	out.WriteString(strings.Join(bindings, ", "))
+	out.WriteString(") ")
+	out.WriteString(we.Body.String())
+
+	return out.String()
+}
+
+// YieldExpression hands a value to the consumer of the generator running it, evaluating to null
+// once the generator is resumed
+type YieldExpression struct {
+	Spanned
+	Token token.Token // the 'yield' token
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	return "yield " + ye.Value.String()
+}
+
 type BlockStatement struct {
+	Spanned
 	Token      token.Token
 	Statements []Statement
 }
 
 type FunctionLiteral struct {
+	Spanned
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Name       string // set when the literal is bound directly by a let statement, empty otherwise
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -248,6 +451,7 @@ func (fl *FunctionLiteral) String() string {
 }
 
 type CallExpression struct {
+	Spanned
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or Function Literal
 	Arguments []Expression
@@ -271,7 +475,52 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// MethodCallExpression is sugar for calling Method with Receiver as its first argument:
+// receiver.method(a, b) is method(receiver, a, b)
+type MethodCallExpression struct {
+	Spanned
+	Token     token.Token // The '.' token
+	Receiver  Expression
+	Method    *Identifier
+	Arguments []Expression
+}
+
+func (mc *MethodCallExpression) expressionNode()      {}
+func (mc *MethodCallExpression) TokenLiteral() string { return mc.Token.Literal }
+func (mc *MethodCallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range mc.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(mc.Receiver.String())
+	out.WriteString(".")
+	out.WriteString(mc.Method.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// MemberExpression accesses a member by name without calling anything: Color.Red
+type MemberExpression struct {
+	Spanned
+	Token  token.Token // The '.' token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Member.String()
+}
+
 type ArrayLiteral struct {
+	Spanned
 	Token    token.Token // The '[' token
 	Elements []Expression
 }
@@ -294,6 +543,7 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
+	Spanned
 	Token token.Token // The '[' token
 	Left  Expression
 	Index Expression
@@ -313,9 +563,45 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is left[start:end:step], where any of the three may be omitted and left nil
+type SliceExpression struct {
+	Spanned
+	Token token.Token // The '[' token
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type HashLiteral struct {
+	Spanned
 	Token token.Token // The '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -325,8 +611,8 @@ func (hl *HashLiteral) String() string {
 
 	pairs := []string{}
 
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"github.com/kahvecikaan/monkey-lang/token"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +27,56 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got = %s", program.String())
 	}
 }
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+
+	// let x = if (c) { f(a, [b]) } else { fn(y) { y.m } };
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("x"),
+				Value: &IfExpression{
+					Condition: ident("c"),
+					Consequence: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &CallExpression{
+							Function:  ident("f"),
+							Arguments: []Expression{ident("a"), &ArrayLiteral{Elements: []Expression{ident("b")}}},
+						}},
+					}},
+					Alternative: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &FunctionLiteral{
+							Parameters: []*Identifier{ident("y")},
+							Body: &BlockStatement{Statements: []Statement{
+								&ExpressionStatement{Expression: &MemberExpression{Object: ident("y"), Member: ident("m")}},
+							}},
+						}},
+					}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		skip     func(Node) bool
+		expected string
+	}{
+		{func(Node) bool { return false }, "x c f a b y y m"},
+		{func(node Node) bool { _, ok := node.(*FunctionLiteral); return ok }, "x c f a b"},
+		{func(node Node) bool { _, ok := node.(*IfExpression); return ok }, "x"},
+	}
+
+	for _, tt := range tests {
+		names := []string{}
+		Inspect(program, func(node Node) bool {
+			if ident, ok := node.(*Identifier); ok {
+				names = append(names, ident.Value)
+			}
+			return !tt.skip(node)
+		})
+
+		if got := strings.Join(names, " "); got != tt.expected {
+			t.Errorf("wrong identifiers visited. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ast
+
+// Inspect calls f with node, then, as long as f returns true, goes on with the children of node in
+// source order, the names a node binds included. A nil node, or a missing child such as the else
+// block of an if, is skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	for _, child := range children(node) {
+		Inspect(child, f)
+	}
+}
+
+func children(node Node) []Node {
+	nodes := []Node{}
+	add := func(exps ...Expression) {
+		for _, exp := range exps {
+			if exp != nil {
+				nodes = append(nodes, exp)
+			}
+		}
+	}
+	addIdents := func(idents ...*Identifier) {
+		for _, ident := range idents {
+			if ident != nil {
+				nodes = append(nodes, ident)
+			}
+		}
+	}
+	addBlock := func(block *BlockStatement) {
+		if block != nil {
+			nodes = append(nodes, block)
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			nodes = append(nodes, stmt)
+		}
+	case *BlockStatement:
+		for _, stmt := range node.Statements {
+			nodes = append(nodes, stmt)
+		}
+	case *LetStatement:
+		addIdents(node.Name)
+		add(node.Value)
+	case *EnumStatement:
+		addIdents(node.Name)
+		addIdents(node.Members...)
+	case *GuardStatement:
+		add(node.Condition)
+		addBlock(node.Alternative)
+	case *ReturnStatement:
+		add(node.ReturnValue)
+	case *ExpressionStatement:
+		add(node.Expression)
+	case *PrefixExpression:
+		add(node.Right)
+	case *InfixExpression:
+		add(node.Left, node.Right)
+	case *IfExpression:
+		add(node.Condition)
+		addBlock(node.Consequence)
+		addBlock(node.Alternative)
+	case *ReturnExpression:
+		add(node.ReturnValue)
+	case *DoExpression:
+		addBlock(node.Body)
+	case *WithExpression:
+		for i, name := range node.Names {
+			addIdents(name)
+			add(node.Values[i])
+		}
+		addBlock(node.Body)
+	case *YieldExpression:
+		add(node.Value)
+	case *FunctionLiteral:
+		addIdents(node.Parameters...)
+		addBlock(node.Body)
+	case *CallExpression:
+		add(node.Function)
+		add(node.Arguments...)
+	case *MethodCallExpression:
+		add(node.Receiver)
+		addIdents(node.Method)
+		add(node.Arguments...)
+	case *MemberExpression:
+		add(node.Object)
+		addIdents(node.Member)
+	case *ArrayLiteral:
+		add(node.Elements...)
+	case *IndexExpression:
+		add(node.Left, node.Index)
+	case *SliceExpression:
+		add(node.Left, node.Start, node.End, node.Step)
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			add(key, node.Pairs[key])
+		}
+	}
+
+	return nodes
+}
@@ -1,19 +1,31 @@
 package evaluator
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/kahvecikaan/monkey-lang/object"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
+		Doc: "len(x) -> integer: the number of elements of an array or bytes of a string, 0 for null",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got = %d, want = 1",
 					len(args))
 			}
 
-			switch arg := args[0].(type) {
+			switch arg := orEmpty(args[0]).(type) {
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
@@ -25,18 +37,19 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": &object.Builtin{
+		Doc: "first(arr) -> any: the first element of arr, or null when it's empty",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got = %d, want = 1",
 					len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJ {
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
 				return newError("argument to `first` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
 			if len(arr.Elements) > 0 {
 				return arr.Elements[0]
 			}
@@ -45,18 +58,19 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
+		Doc: "last(arr) -> any: the last element of arr, or null when it's empty",
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got = %d, want = 1",
 					len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJ {
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
 				return newError("argument to `last` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
 			if length > 0 {
 				return arr.Elements[length-1]
@@ -66,20 +80,26 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Doc: "rest(arr) -> array: arr without its first element, or null when it's empty",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got = %d, want = 1",
 					len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJ {
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
 				return newError("argument to `rest` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
 			if length > 0 {
+				if errObj := ev.allocate(length - 1); errObj != nil {
+					return errObj
+				}
 				newElements := make([]object.Object, length-1)
 				copy(newElements, arr.Elements[1:length])
 				return &object.Array{Elements: newElements}
@@ -89,20 +109,26 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Doc: "push(arr, x) -> array: a copy of arr with x appended",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got = %d, want = 2",
 					len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJ {
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
 				return newError("first argument to `push` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
 
+			if errObj := ev.allocate(length + 1); errObj != nil {
+				return errObj
+			}
 			newElements := make([]object.Object, length+1)
 			copy(newElements, arr.Elements)
 			elemToPush := args[1]
@@ -111,13 +137,1999 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
-	"puts": &object.Builtin{
+	"swap": &object.Builtin{
+		Doc: "swap(arr, i, j) -> array: exchanges the elements at i and j in arr itself, and returns arr",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got = %d, want = 3",
+					len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `swap` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			// unlike push, swap changes arr: every binding of the array sees the new order
+			indices := [2]int64{}
+			for n, arg := range args[1:] {
+				index, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("indices to `swap` must be INTEGER, got %s", arg.Type())
+				}
+				if index.Value < 0 || index.Value >= int64(len(arr.Elements)) {
+					return newError("index %d out of range for `swap` on an array of length %d",
+						index.Value, len(arr.Elements))
+				}
+				indices[n] = index.Value
+			}
+
+			i, j := indices[0], indices[1]
+			arr.Elements[i], arr.Elements[j] = arr.Elements[j], arr.Elements[i]
+
+			return arr
+		},
+	},
+	"concat": &object.Builtin{
+		Doc: "concat(xs...) -> array or string: the arrays, or the strings, joined into a new one",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got = %d, want at least 1",
+					len(args))
+			}
+
+			colls := make([]object.Object, len(args))
+			for i, arg := range args {
+				colls[i] = orEmpty(arg)
+			}
+
+			kind := colls[0].Type()
+			if kind != object.ARRAY_OBJ && kind != object.STRING_OBJ {
+				return newError("arguments to `concat` must be ARRAY or STRING, got %s", kind)
+			}
+
+			length := 0
+			for i, arg := range colls {
+				if arg.Type() != kind {
+					return newError("argument %d to `concat` must be %s like the first, got %s",
+						i+1, kind, args[i].Type())
+				}
+				if arr, ok := arg.(*object.Array); ok {
+					length += len(arr.Elements)
+				} else {
+					length += len(arg.(*object.String).Value)
+				}
+			}
+
+			if errObj := ev.allocate(length); errObj != nil {
+				return errObj
+			}
+
+			if kind == object.STRING_OBJ {
+				var joined strings.Builder
+				joined.Grow(length)
+				for _, arg := range colls {
+					joined.WriteString(arg.(*object.String).Value)
+				}
+				return object.NewString(joined.String())
+			}
+
+			elements := make([]object.Object, 0, length)
+			for _, arg := range colls {
+				elements = append(elements, arg.(*object.Array).Elements...)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"flatten": &object.Builtin{
+		Doc: "flatten(arr, depth?) -> array: arr with nested arrays spliced in, depth levels deep (1 by default)",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 1 or 2",
+					len(args))
+			}
+
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
+				return newError("first argument to `flatten` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			depth := int64(1)
+			if len(args) == 2 {
+				d, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `flatten` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				if d.Value < 0 {
+					return newError("second argument to `flatten` must not be negative, got %d",
+						d.Value)
+				}
+				depth = d.Value
+			}
+
+			// the same array can be nested many times over, so the result can be much larger
+			// than the input
+			length := flattenedLen(arr, depth)
+			if errObj := ev.allocate(length); errObj != nil {
+				return errObj
+			}
+
+			return &object.Array{Elements: flattenInto(make([]object.Object, 0, length), arr, depth)}
+		},
+	},
+	"frequencies": &object.Builtin{
+		Doc: "frequencies(arr) -> hash: the number of times each distinct element occurs in arr",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
+				return newError("argument to `frequencies` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			if errObj := ev.allocate(len(arr.Elements)); errObj != nil {
+				return errObj
+			}
+
+			counts := object.NewHash()
+			for _, e := range arr.Elements {
+				hashable, ok := e.(object.Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s (%s)", e.Type(), e.Inspect())
+				}
+
+				n := int64(0)
+				if pair, found := counts.Pairs[hashable.HashKey()].FindPair(e); found {
+					n = pair.Value.(*object.Integer).Value
+				}
+
+				// the element is hashable, so adding it can't fail
+				counts.Add(e, object.NewInteger(n+1))
+			}
+
+			return counts
+		},
+	},
+	"unique": &object.Builtin{
+		Doc: "unique(arr) -> array: arr without the elements equal to an earlier one",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
+				return newError("argument to `unique` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			if errObj := ev.allocate(len(arr.Elements)); errObj != nil {
+				return errObj
+			}
+
+			// hashable elements are looked up in seen; the rest are compared one by one, and so
+			// are the integers against the floats kept so far
+			seen := object.NewSet()
+			kept, unhashable := []object.Object{}, []object.Object{}
+			for _, e := range arr.Elements {
+				var duplicate bool
+				if _, ok := e.(object.Hashable); ok {
+					duplicate = seen.Has(e) || (isNumeric(e) && containsEqual(unhashable, e))
+					seen.Add(e)
+				} else {
+					duplicate = containsEqual(kept, e)
+					if !duplicate {
+						unhashable = append(unhashable, e)
+					}
+				}
+
+				if !duplicate {
+					kept = append(kept, e)
+				}
+			}
+
+			return &object.Array{Elements: kept}
+		},
+	},
+	"sum": &object.Builtin{
+		Doc: "sum(arr) -> number: the elements of arr added together, 0 when it's empty",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			return evaluationFrom(ctx).foldNumbers("sum", "+", object.NewInteger(0), args)
+		},
+	},
+	"product": &object.Builtin{
+		Doc: "product(arr) -> number: the elements of arr multiplied together, 1 when it's empty",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			return evaluationFrom(ctx).foldNumbers("product", "*", object.NewInteger(1), args)
+		},
+	},
+	"entries": &object.Builtin{
+		Doc: "entries(hash) -> array: the [key, value] pairs of hash, in the order it's inspected in",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			if args[0] == NULL {
+				return &object.Array{Elements: []object.Object{}}
+			}
+
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `entries` must be HASH, got %s", args[0].Type())
+			}
+
+			// hashes don't keep the order their keys were added in, so the pairs are sorted by key
+			// like Inspect sorts them
+			pairs := hash.SortedPairs()
+			if errObj := ev.allocate(len(pairs)); errObj != nil {
+				return errObj
+			}
+
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"getIn": &object.Builtin{
+		Doc: "getIn(data, path, default = null) -> any: the value reached by indexing data with each element of path in turn, or default if one is missing",
 		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got = %d, want = 2 or 3",
+					len(args))
+			}
+
+			path, ok := args[1].(*object.Array)
+			if !ok {
+				return newError("second argument to `getIn` must be ARRAY, got %s",
+					args[1].Type())
+			}
+			var fallback object.Object = NULL
+			if len(args) == 3 {
+				fallback = args[2]
+			}
+
+			// a null along the way is as missing as a key that isn't there
+			current := args[0]
+			for i, key := range path.Elements {
+				if current == NULL {
+					return fallback
+				}
+
+				value, found, errObj := pathStep("getIn", current, key, i)
+				if errObj != nil {
+					return errObj
+				}
+				if !found {
+					return fallback
+				}
+				current = value
+			}
+
+			return current
+		},
+	},
+	"setIn": &object.Builtin{
+		Doc: "setIn(data, path, value) -> any: a copy of data with value at the end of path, adding hashes for missing keys",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got = %d, want = 3",
+					len(args))
+			}
+
+			path, ok := args[1].(*object.Array)
+			if !ok {
+				return newError("second argument to `setIn` must be ARRAY, got %s",
+					args[1].Type())
+			}
+
+			return ev.setIn(args[0], path.Elements, 0, args[2])
+		},
+	},
+	"clamp": &object.Builtin{
+		Doc: "clamp(x, lo, hi) -> number: x, or lo when x is below it, or hi when x is above it",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got = %d, want = 3",
+					len(args))
+			}
+
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				if !isNumber(arg) {
+					return newError("arguments to `clamp` must be numbers, got %s", arg.Type())
+				}
+			}
+
+			// the bound that applies is returned as it was given, so types can mix
+			x, lo, hi := args[0], args[1], args[2]
+			if ev.numberLess(hi, lo) {
+				return newError("lower bound of `clamp` must not be above the upper one, got %s > %s",
+					lo.Inspect(), hi.Inspect())
+			}
+
+			switch {
+			case ev.numberLess(x, lo):
+				return lo
+			case ev.numberLess(hi, x):
+				return hi
+			default:
+				return x
+			}
+		},
+	},
+	"sign": &object.Builtin{
+		Doc: "sign(x) -> integer: -1, 0 or 1 as x is negative, zero or positive",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			var sign int
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				switch {
+				case arg.Value < 0:
+					sign = -1
+				case arg.Value > 0:
+					sign = 1
+				}
+			case *object.BigInt:
+				sign = arg.Value.Sign()
+			case *object.Float:
+				if math.IsNaN(arg.Value) {
+					return newError("argument to `sign` is nan, which has no sign")
+				}
+				switch {
+				case arg.Value < 0:
+					sign = -1
+				case arg.Value > 0:
+					sign = 1
+				}
+			default:
+				return newError("argument to `sign` must be a number, got %s", args[0].Type())
+			}
+
+			return object.NewInteger(int64(sign))
+		},
+	},
+	"isTailRecursive": &object.Builtin{
+		Doc: "isTailRecursive(fn) -> boolean: whether fn calls itself, and only as the last thing it does",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			fn, ok := args[0].(*object.Function)
+			if !ok {
+				return newError("argument to `isTailRecursive` must be FUNCTION, got %s",
+					args[0].Type())
+			}
+
+			return nativeBoolToBooleanObject(isTailRecursive(fn))
+		},
+	},
+	"next": &object.Builtin{
+		Doc: "next(gen) -> any: resumes gen up to its next yield and returns the value, null once it's done",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			gen, errObj := generatorArgument("next", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			value, ok := gen.Next(ctx)
+			if !ok {
+				return NULL
+			}
+
+			return value
+		},
+	},
+	"done": &object.Builtin{
+		Doc: "done(gen) -> boolean: whether gen has no more values, resuming it to find out if needed",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			gen, errObj := generatorArgument("done", args)
+			if errObj != nil {
+				return errObj
 			}
 
+			value, ok := gen.Peek(ctx)
+			if ok && isError(value) {
+				return value
+			}
+
+			return nativeBoolToBooleanObject(!ok)
+		},
+	},
+	"take": &object.Builtin{
+		Doc: "take(gen, n) -> array: the next n values of gen, fewer if it's done before",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 2",
+					len(args))
+			}
+
+			gen, ok := args[0].(*object.Generator)
+			if !ok {
+				return newError("first argument to `take` must be GENERATOR, got %s",
+					args[0].Type())
+			}
+
+			n, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to `take` must be INTEGER, got %s",
+					args[1].Type())
+			}
+			if n.Value < 0 {
+				return newError("second argument to `take` must not be negative, got %d",
+					n.Value)
+			}
+
+			return ev.collectGenerator(gen, n.Value)
+		},
+	},
+	"toArray": &object.Builtin{
+		Doc: "toArray(gen) -> array: all the remaining values of gen, which must finish",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) == 1 && args[0] == NULL {
+				return orEmpty(NULL)
+			}
+
+			gen, errObj := generatorArgument("toArray", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			return ev.collectGenerator(gen, -1)
+		},
+	},
+	"close": &object.Builtin{
+		Doc: "close(gen) -> null: ends gen early, stopping its function at the yield it's waiting in",
+		Fn: func(args ...object.Object) object.Object {
+			gen, errObj := generatorArgument("close", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			gen.Close()
+
 			return NULL
 		},
 	},
+	"bool": &object.Builtin{
+		Doc: "bool(x) -> boolean: parses \"true\" and \"false\", other values follow truthiness",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			// "true" and "false" are parsed, any other value follows the truthiness rules
+			if str, ok := args[0].(*object.String); ok {
+				switch str.Value {
+				case "true":
+					return TRUE
+				case "false":
+					return FALSE
+				}
+			}
+
+			return nativeBoolToBooleanObject(isTruthy(args[0]))
+		},
+	},
+	"truthy": &object.Builtin{
+		Doc: "truthy(x) -> boolean: whether x counts as true in a condition",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			return nativeBoolToBooleanObject(isTruthy(args[0]))
+		},
+	},
+	"inf": &object.Builtin{
+		Doc: "inf() -> float: positive infinity, negate it for negative infinity",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got = %d, want = 0",
+					len(args))
+			}
+
+			return object.NewFloat(math.Inf(1))
+		},
+	},
+	"nan": &object.Builtin{
+		Doc: "nan() -> float: not a number, which is unequal to everything including itself",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got = %d, want = 0",
+					len(args))
+			}
+
+			return object.NewFloat(math.NaN())
+		},
+	},
+	"float": &object.Builtin{
+		Doc: "float(x) -> float: converts an integer or bigint, or parses a string as a float",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Float:
+				return arg
+			case *object.Integer:
+				return object.NewFloat(float64(arg.Value))
+			case *object.BigInt:
+				value, _ := new(big.Float).SetInt(arg.Value).Float64()
+				return object.NewFloat(value)
+			case *object.String:
+				value, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return newError("could not parse %q as float", arg.Value)
+				}
+				return object.NewFloat(value)
+			default:
+				return newError("argument to `float` not supported, got = %s",
+					args[0].Type())
+			}
+		},
+	},
+	"bigint": &object.Builtin{
+		Doc: "bigint(x) -> bigint: converts an integer or parses a decimal string as an arbitrary-precision integer",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.BigInt:
+				return arg
+			case *object.Integer:
+				return object.NewBigInt(big.NewInt(arg.Value))
+			case *object.String:
+				value, ok := new(big.Int).SetString(arg.Value, 10)
+				if !ok {
+					return newError("could not parse %q as bigint", arg.Value)
+				}
+				return object.NewBigInt(value)
+			default:
+				return newError("argument to `bigint` not supported, got = %s",
+					args[0].Type())
+			}
+		},
+	},
+	"parseInt": &object.Builtin{
+		Doc: "parseInt(s, radix = 10) -> integer: parses s as an integer in the given base, from 2 to 36",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 1 or 2",
+					len(args))
+			}
+
+			s, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `parseInt` must be STRING, got %s",
+					args[0].Type())
+			}
+			radix := int64(10)
+			if len(args) == 2 {
+				r, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `parseInt` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				if r.Value < 2 || r.Value > 36 {
+					return newError("radix to `parseInt` must be between 2 and 36, got %d", r.Value)
+				}
+				radix = r.Value
+			}
+
+			// surrounding whitespace is ignored, then a single optional sign may precede the
+			// digits. Prefixes like 0x aren't understood, the radix says how to read the digits
+			value, err := strconv.ParseInt(strings.TrimSpace(s.Value), int(radix), 64)
+			if errors.Is(err, strconv.ErrRange) {
+				return newError("%q is out of range for an integer", s.Value)
+			}
+			if err != nil {
+				return newError("could not parse %q as an integer in base %d", s.Value, radix)
+			}
+
+			return object.NewInteger(value)
+		},
+	},
+	"parseFloat": &object.Builtin{
+		Doc: "parseFloat(s) -> float: parses s as a decimal float, an exponent, inf or nan",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			s, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `parseFloat` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			// like parseInt, surrounding whitespace is ignored and a single sign is allowed
+			value, err := strconv.ParseFloat(strings.TrimSpace(s.Value), 64)
+			if errors.Is(err, strconv.ErrRange) && math.IsInf(value, 0) {
+				return newError("%q is out of range for a float", s.Value)
+			}
+			if err != nil && !errors.Is(err, strconv.ErrRange) {
+				return newError("could not parse %q as a float", s.Value)
+			}
+
+			// values too small to represent round to zero, as float literals do
+			return object.NewFloat(value)
+		},
+	},
+	"arity": &object.Builtin{
+		Doc: "arity(fn) -> integer: the number of parameters of fn, -1 for builtins",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			switch fn := args[0].(type) {
+			case *object.Function:
+				return object.NewInteger(int64(len(fn.Parameters)))
+			case *object.Builtin:
+				// builtins validate their own arguments, so they are reported as variadic
+				return object.NewInteger(-1)
+			default:
+				return newError("argument to `arity` must be FUNCTION or BUILTIN, got %s",
+					args[0].Type())
+			}
+		},
+	},
+	"isCallable": &object.Builtin{
+		Doc: "isCallable(x) -> boolean: whether x is a function or builtin",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			return nativeBoolToBooleanObject(isCallable(args[0]))
+		},
+	},
+	"hashable": &object.Builtin{
+		Doc: "hashable(x) -> boolean: whether x can be a hash key or set element",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			// the values usable as hash keys are also the ones usable as set elements
+			_, ok := args[0].(object.Hashable)
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	"set": &object.Builtin{
+		Doc: "set(arr) -> set: the distinct elements of arr",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			arr, ok := orEmpty(args[0]).(*object.Array)
+			if !ok {
+				return newError("argument to `set` must be ARRAY, got %s",
+					args[0].Type())
+			}
+
+			elements := arr.Elements
+			if errObj := ev.allocate(len(elements)); errObj != nil {
+				return errObj
+			}
+
+			set := object.NewSet()
+			for _, e := range elements {
+				if err := set.Add(e); err != nil {
+					return newError("%s", err.Error())
+				}
+			}
+
+			return set
+		},
+	},
+	"add": &object.Builtin{
+		Doc: "add(s, x) -> set: a copy of s with x added",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 2",
+					len(args))
+			}
+
+			if args[0].Type() != object.SET_OBJ {
+				return newError("first argument to `add` must be SET, got %s",
+					args[0].Type())
+			}
+
+			if errObj := ev.allocate(args[0].(*object.Set).Len() + 1); errObj != nil {
+				return errObj
+			}
+
+			set := args[0].(*object.Set).Copy()
+			if err := set.Add(args[1]); err != nil {
+				return newError("%s", err.Error())
+			}
+
+			return set
+		},
+	},
+	"remove": &object.Builtin{
+		Doc: "remove(s, x) -> set: a copy of s without x",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 2",
+					len(args))
+			}
+
+			if args[0].Type() != object.SET_OBJ {
+				return newError("first argument to `remove` must be SET, got %s",
+					args[0].Type())
+			}
+
+			set := args[0].(*object.Set).Copy()
+			set.Remove(args[1])
+
+			return set
+		},
+	},
+	"has": &object.Builtin{
+		Doc: "has(s, x) -> boolean: whether x is a member of s",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 2",
+					len(args))
+			}
+
+			set, ok := orEmptySet(args[0]).(*object.Set)
+			if !ok {
+				return newError("first argument to `has` must be SET, got %s",
+					args[0].Type())
+			}
+
+			return nativeBoolToBooleanObject(set.Has(args[1]))
+		},
+	},
+	"size": &object.Builtin{
+		Doc: "size(s) -> integer: the number of members of s",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			set, ok := orEmptySet(args[0]).(*object.Set)
+			if !ok {
+				return newError("argument to `size` must be SET, got %s",
+					args[0].Type())
+			}
+
+			return &object.Integer{Value: int64(set.Len())}
+		},
+	},
+	"union": &object.Builtin{
+		Doc: "union(a, b) -> set: the members of either set",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			a, b, errObj := setOperands("union", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			if errObj := ev.allocate(a.Len() + b.Len()); errObj != nil {
+				return errObj
+			}
+
+			result := a.Copy()
+			for _, m := range b.Members() {
+				// members of a set are always hashable
+				result.Add(m)
+			}
+
+			return result
+		},
+	},
+	"intersect": &object.Builtin{
+		Doc: "intersect(a, b) -> set: the members of both sets",
+		Fn: func(args ...object.Object) object.Object {
+			a, b, errObj := setOperands("intersect", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			result := object.NewSet()
+			for _, m := range a.Members() {
+				if b.Has(m) {
+					result.Add(m)
+				}
+			}
+
+			return result
+		},
+	},
+	"difference": &object.Builtin{
+		Doc: "difference(a, b) -> set: the members of a that aren't in b",
+		Fn: func(args ...object.Object) object.Object {
+			a, b, errObj := setOperands("difference", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			result := object.NewSet()
+			for _, m := range a.Members() {
+				if !b.Has(m) {
+					result.Add(m)
+				}
+			}
+
+			return result
+		},
+	},
+	"assert": &object.Builtin{
+		Doc: "assert(cond, message?) -> null: fails with message unless cond is truthy",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 1 or 2",
+					len(args))
+			}
+
+			if isTruthy(args[0]) {
+				return NULL
+			}
+
+			if len(args) == 2 {
+				return newError("%s", assertionMessage(args[1]))
+			}
+			return newError("assertion failed")
+		},
+	},
+	"assertEq": &object.Builtin{
+		Doc: "assertEq(a, b, message?) -> null: fails unless a and b are equal",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got = %d, want = 2 or 3",
+					len(args))
+			}
+
+			if objectsEqual(args[0], args[1]) {
+				return NULL
+			}
+
+			msg := "assertion failed"
+			if len(args) == 3 {
+				msg = assertionMessage(args[2])
+			}
+			return newError("%s: %s != %s", msg, args[0].Inspect(), args[1].Inspect())
+		},
+	},
+	"expect": &object.Builtin{
+		Doc: "expect(x, type) -> any: x when its type is the one named, such as \"INTEGER\", and an error otherwise",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got = %d, want = 2",
+					len(args))
+			}
+
+			name, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `expect` must be STRING, got %s",
+					args[1].Type())
+			}
+			if !object.IsObjectType(name.Value) {
+				return newError("unknown type %q given to `expect`", name.Value)
+			}
+
+			if args[0].Type() != object.ObjectType(name.Value) {
+				return newError("expected %s, got %s (%s)", name.Value, args[0].Type(), args[0].Inspect())
+			}
+
+			return args[0]
+		},
+	},
+	"base64Encode": &object.Builtin{
+		Doc: "base64Encode(s) -> string: the standard, padded base64 encoding of the bytes of s",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			s, errObj := stringArgument("base64Encode", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			encoded := base64.StdEncoding.EncodeToString([]byte(s))
+			if errObj := ev.allocate(len(encoded)); errObj != nil {
+				return errObj
+			}
+			return object.NewString(encoded)
+		},
+	},
+	"base64Decode": &object.Builtin{
+		Doc: "base64Decode(s) -> string: the bytes encoded by the standard, padded base64 string s",
+		FnContext: func(ctx context.Context, args ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			s, errObj := stringArgument("base64Decode", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return newError("could not decode %q as base64: %s", s, err)
+			}
+			if errObj := ev.allocate(len(decoded)); errObj != nil {
+				return errObj
+			}
+			return object.NewString(string(decoded))
+		},
+	},
+	"sha256": &object.Builtin{
+		Doc: "sha256(s) -> string: the SHA-256 digest of the bytes of s, in lowercase hex",
+		Fn: func(args ...object.Object) object.Object {
+			s, errObj := stringArgument("sha256", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			digest := sha256.Sum256([]byte(s))
+			return object.NewString(hex.EncodeToString(digest[:]))
+		},
+	},
+	"md5": &object.Builtin{
+		Doc: "md5(s) -> string: the MD5 digest of the bytes of s, in lowercase hex, for checksums rather than security",
+		Fn: func(args ...object.Object) object.Object {
+			s, errObj := stringArgument("md5", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			digest := md5.Sum([]byte(s))
+			return object.NewString(hex.EncodeToString(digest[:]))
+		},
+	},
+	"pp": &object.Builtin{
+		Doc: "pp(x) -> null: prints x like puts, spreading nested arrays, hashes and sets over indented lines",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			fmt.Println(object.PrettyInspect(args[0]))
+
+			return NULL
+		},
+	},
+}
+
+// flattenedLen counts the elements flatten produces for arr
+func flattenedLen(arr *object.Array, depth int64) int {
+	if depth == 0 {
+		return len(arr.Elements)
+	}
+
+	length := 0
+	for _, e := range arr.Elements {
+		if nested, ok := e.(*object.Array); ok {
+			length += flattenedLen(nested, depth-1)
+		} else {
+			length++
+		}
+	}
+
+	return length
+}
+
+// flattenInto appends the elements of arr to result, splicing in nested arrays depth levels deep
+func flattenInto(result []object.Object, arr *object.Array, depth int64) []object.Object {
+	for _, e := range arr.Elements {
+		if nested, ok := e.(*object.Array); ok && depth > 0 {
+			result = flattenInto(result, nested, depth-1)
+		} else {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// numberLess reports whether a < b for two numbers of any kind
+func (ev *evaluation) numberLess(a, b object.Object) bool {
+	return ev.evalInfixExpression("<", a, b) == TRUE
+}
+
+// foldNumbers combines the numbers in the array argument of sum or product with operator, starting
+// from identity. The operator promotes the result the way it does between two numbers, so one float
+// element makes it a float, and integers overflowing are reported unless they wrap.
+func (ev *evaluation) foldNumbers(
+	name, operator string, identity object.Object, args []object.Object,
+) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	arr, ok := orEmpty(args[0]).(*object.Array)
+	if !ok {
+		return newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	for i, e := range arr.Elements {
+		if !isNumber(e) {
+			return newError("element %d of the argument to `%s` must be a number, got %s",
+				i, name, e.Type())
+		}
+	}
+
+	result := identity
+	for _, e := range arr.Elements {
+		result = ev.evalInfixExpression(operator, result, e)
+		if isError(result) {
+			return result
+		}
+	}
+
+	return result
+}
+
+// pathStep indexes coll with path[i] for getIn and setIn, reporting whether it's there
+func pathStep(name string, coll, key object.Object, i int) (object.Object, bool, *object.Error) {
+	switch coll := coll.(type) {
+	case *object.Array:
+		index, ok := key.(*object.Integer)
+		if !ok {
+			return nil, false, newError("path[%d] of `%s` must be INTEGER to index an ARRAY, got %s",
+				i, name, key.Type())
+		}
+		if index.Value < 0 || index.Value >= int64(len(coll.Elements)) {
+			return nil, false, nil
+		}
+		return coll.Elements[index.Value], true, nil
+	case *object.Hash:
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return nil, false, newError("unusable as hash key: %s (%s)", key.Type(), key.Inspect())
+		}
+		pair, found := coll.Pairs[hashable.HashKey()].FindPair(key)
+		return pair.Value, found, nil
+	default:
+		return nil, false, newError("path[%d] of `%s` can't index %s, only ARRAY or HASH",
+			i, name, coll.Type())
+	}
+}
+
+// setIn returns a copy of data with path[i:] leading to value. Only the arrays and hashes along
+// the path are copied, everything else is shared with data. A null, or a key missing from a hash,
+// becomes an empty hash when there's more path to go, but array indices must already exist.
+func (ev *evaluation) setIn(
+	data object.Object, path []object.Object, i int, value object.Object,
+) object.Object {
+	if i == len(path) {
+		return value
+	}
+	if data == NULL {
+		data = object.NewHash()
+	}
+
+	child, found, errObj := pathStep("setIn", data, path[i], i)
+	if errObj != nil {
+		return errObj
+	}
+
+	// pathStep has made sure data is an array or a hash
+	if arr, ok := data.(*object.Array); ok {
+		index := path[i].(*object.Integer).Value
+		if !found {
+			return newError("path[%d] of `setIn` is out of range for an array of length %d, got %d",
+				i, len(arr.Elements), index)
+		}
+
+		updated := ev.setIn(child, path, i+1, value)
+		if isError(updated) {
+			return updated
+		}
+		if errObj := ev.allocate(len(arr.Elements)); errObj != nil {
+			return errObj
+		}
+		elements := append([]object.Object(nil), arr.Elements...)
+		elements[index] = updated
+		return &object.Array{Elements: elements}
+	}
+
+	if !found {
+		child = NULL
+	}
+
+	updated := ev.setIn(child, path, i+1, value)
+	if isError(updated) {
+		return updated
+	}
+	hash := data.(*object.Hash)
+	if errObj := ev.allocate(len(hash.Pairs) + 1); errObj != nil {
+		return errObj
+	}
+	copied := hash.Copy()
+	copied.Add(path[i], updated)
+	return copied
+}
+
+// containsEqual reports whether any of elements is equal to obj
+func containsEqual(elements []object.Object, obj object.Object) bool {
+	for _, e := range elements {
+		if objectsEqual(e, obj) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringArgument checks the argument of the builtins that take a single string
+func stringArgument(name string, args []object.Object) (string, *object.Error) {
+	if len(args) != 1 {
+		return "", newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	return s.Value, nil
+}
+
+// generatorArgument checks the argument of the builtins that take a generator
+func generatorArgument(name string, args []object.Object) (*object.Generator, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	gen, ok := args[0].(*object.Generator)
+	if !ok {
+		return nil, newError("argument to `%s` must be GENERATOR, got %s", name, args[0].Type())
+	}
+
+	return gen, nil
+}
+
+// collectGenerator gathers up to limit values of gen into an array, all of them when limit is
+// negative. Each value is allocated as it arrives, so the allocation limit stops an infinite
+// generator even when the step limit doesn't.
+func (ev *evaluation) collectGenerator(gen *object.Generator, limit int64) object.Object {
+	elements := []object.Object{}
+	for limit < 0 || int64(len(elements)) < limit {
+		value, ok := gen.Next(ev)
+		if !ok {
+			break
+		}
+		if isError(value) {
+			return value
+		}
+
+		if errObj := ev.allocate(1); errObj != nil {
+			return errObj
+		}
+		elements = append(elements, value)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// setOperands checks the arguments of the builtin set operations, which take two sets
+func setOperands(name string, args []object.Object) (*object.Set, *object.Set, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	a, ok := args[0].(*object.Set)
+	if !ok {
+		return nil, nil, newError("first argument to `%s` must be SET, got %s", name, args[0].Type())
+	}
+
+	b, ok := args[1].(*object.Set)
+	if !ok {
+		return nil, nil, newError("second argument to `%s` must be SET, got %s", name, args[1].Type())
+	}
+
+	return a, b, nil
+}
+
+// assertionMessage uses a string message as it is and inspects anything else
+func assertionMessage(msg object.Object) string {
+	if str, ok := msg.(*object.String); ok {
+		return str.Value
+	}
+
+	return msg.Inspect()
+}
+
+// IsBuiltin reports whether name refers to a builtin function
+func IsBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
+}
+
+// RegisterBuiltin makes fn callable from Monkey code as name, alongside the builtins the language
+// ships with. It returns an error instead of replacing a builtin that's already registered. Like
+// the builtins themselves, the registry is shared by every evaluation, so hosts should register
+// their functions before evaluating anything.
+func RegisterBuiltin(name string, fn object.BuiltinFunction) error {
+	return registerBuiltin(&object.Builtin{Name: name, Fn: fn})
+}
+
+// RegisterBuiltinContext is RegisterBuiltin for a function that's given the context of the
+// evaluation calling it. Passing that context to CallFunctionContext or EvalContext calls back into
+// Monkey code as part of the same evaluation, within its limits.
+func RegisterBuiltinContext(name string, fn object.ContextBuiltinFunction) error {
+	return registerBuiltin(&object.Builtin{Name: name, FnContext: fn})
+}
+
+func registerBuiltin(builtin *object.Builtin) error {
+	if _, ok := builtins[builtin.Name]; ok {
+		return fmt.Errorf("builtin already registered: %s", builtin.Name)
+	}
+
+	builtins[builtin.Name] = builtin
+	return nil
+}
+
+// Builtins that call back into the evaluator or read the builtins themselves are registered
+// here: referencing applyFunction or builtins from the builtins initializer would create an
+// initialization cycle.
+func init() {
+	builtins["partial"] = &object.Builtin{
+		Doc: "partial(fn, args...) -> builtin: fn with its leading arguments bound to args",
+		Fn:  partial,
+	}
+	builtins["compose"] = &object.Builtin{
+		Doc: "compose(fns...) -> builtin: calls the functions right to left, each on the last result",
+		Fn:  compose,
+	}
+	builtins["memoize"] = &object.Builtin{
+		Doc: "memoize(fn) -> builtin: fn, remembering its result for every list of hashable arguments",
+		Fn:  memoize,
+	}
+	builtins["generator"] = &object.Builtin{
+		Doc: "generator(fn, args...) -> generator: the values fn(args...) yields, computed as next asks for them",
+		Fn:  generator,
+	}
+	builtins["apply"] = &object.Builtin{
+		Doc:       "apply(fn, args) -> any: calls fn with the elements of the array args as its arguments",
+		FnContext: apply,
+	}
+	builtins["times"] = &object.Builtin{
+		Doc:       "times(n, fn) -> array: the results of calling fn with each index from 0 to n - 1",
+		FnContext: times,
+	}
+	builtins["fill"] = &object.Builtin{
+		Doc:       "fill(n, x) -> array: n elements that are all x, or fn(index) for each index when x is a function",
+		FnContext: fill,
+	}
+	builtins["each"] = &object.Builtin{
+		Doc:       "each(coll, fn) -> coll: calls fn(element) for arrays and sets, fn(key, value) for hashes",
+		FnContext: each,
+	}
+	builtins["groupBy"] = &object.Builtin{
+		Doc:       "groupBy(arr, fn) -> hash: the elements of arr in arrays keyed by fn(element)",
+		FnContext: groupBy,
+	}
+	builtins["partition"] = &object.Builtin{
+		Doc:       "partition(arr, fn) -> array: [the elements fn is truthy for, the rest]",
+		FnContext: partition,
+	}
+	builtins["count"] = &object.Builtin{
+		Doc:       "count(arr, fn) -> integer: the number of elements fn is truthy for",
+		FnContext: count,
+	}
+	builtins["str"] = &object.Builtin{
+		Doc:       "str(x) -> string: x as puts prints it, using the function under a hash's \"__str__\" key",
+		FnContext: str,
+	}
+	builtins["puts"] = &object.Builtin{
+		Doc:       "puts(x...) -> null: prints each argument on a line of its own",
+		FnContext: puts,
+	}
+	builtins["freeVars"] = &object.Builtin{
+		Doc: "freeVars(fn) -> array: the sorted names fn uses from the environment it was defined in",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got = %d, want = 1",
+					len(args))
+			}
+
+			fn, ok := args[0].(*object.Function)
+			if !ok {
+				return newError("argument to `freeVars` must be FUNCTION, got %s",
+					args[0].Type())
+			}
+
+			names := freeVars(fn)
+			elements := make([]object.Object, len(names))
+			for i, name := range names {
+				elements[i] = object.NewString(name)
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	}
+	builtins["builtins"] = &object.Builtin{
+		Doc: "builtins() -> array: the names of all builtins, sorted",
+		Fn:  builtinNames,
+	}
+	builtins["help"] = &object.Builtin{
+		Doc: "help(name) -> string: the signature and description of a builtin",
+		Fn:  help,
+	}
+
+	for name, builtin := range builtins {
+		builtin.Name = name
+	}
+}
+
+// help looks up the documentation of a builtin, given either its name or the builtin itself
+func help(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	var builtin *object.Builtin
+	switch arg := args[0].(type) {
+	case *object.String:
+		b, ok := builtins[arg.Value]
+		if !ok {
+			return newError("unknown builtin: %s", arg.Value)
+		}
+		builtin = b
+	case *object.Builtin:
+		builtin = arg
+	default:
+		return newError("argument to `help` must be STRING or BUILTIN, got %s", args[0].Type())
+	}
+
+	return object.NewString(BuiltinDoc(builtin))
+}
+
+// apply calls a callable with the elements of an array as its arguments
+func apply(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	fn := args[0]
+	if !isCallable(fn) {
+		return newError("first argument to `apply` must be FUNCTION or BUILTIN, got %s",
+			fn.Type())
+	}
+
+	arr, ok := orEmpty(args[1]).(*object.Array)
+	if !ok {
+		return newError("second argument to `apply` must be ARRAY, got %s", args[1].Type())
+	}
+
+	return ev.applyFunction(fn, arr.Elements)
+}
+
+// times calls a callable n times with the index of the call, collecting the results
+func times(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("first argument to `times` must be INTEGER, got %s", args[0].Type())
+	}
+	if n.Value < 0 {
+		return newError("first argument to `times` must not be negative, got %d", n.Value)
+	}
+
+	fn := args[1]
+	if !isCallable(fn) {
+		return newError("second argument to `times` must be FUNCTION or BUILTIN, got %s",
+			fn.Type())
+	}
+
+	// the results are allocated as they're made, n can be far more than will ever be reached
+	results := []object.Object{}
+	for i := int64(0); i < n.Value; i++ {
+		result := ev.applyFunction(fn, []object.Object{object.NewInteger(i)})
+		if isError(result) {
+			return result
+		}
+
+		if errObj := ev.allocate(1); errObj != nil {
+			return errObj
+		}
+		results = append(results, result)
+	}
+
+	return &object.Array{Elements: results}
+}
+
+// fill makes an array of n elements. A value given as the second argument is shared by all of them
+// rather than copied, which matters for arrays swap can change; a function makes each element
+// separately, the way times does.
+func fill(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("first argument to `fill` must be INTEGER, got %s", args[0].Type())
+	}
+	if n.Value < 0 {
+		return newError("first argument to `fill` must not be negative, got %d", n.Value)
+	}
+
+	if isCallable(args[1]) {
+		return times(ctx, args...)
+	}
+
+	// like times, the array grows one allocated element at a time rather than being made for n,
+	// and each element counts as a step so a huge n can be stopped
+	elements := []object.Object{}
+	for i := int64(0); i < n.Value; i++ {
+		if errObj := ev.checkCanceled(); errObj != nil {
+			return errObj
+		}
+		if errObj := ev.countStep(); errObj != nil {
+			return errObj
+		}
+		if errObj := ev.allocate(1); errObj != nil {
+			return errObj
+		}
+		elements = append(elements, args[1])
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// each calls a callable on every element of a collection for its side effects, returning the
+// collection. Hashes and sets are visited in the order Inspect lists them.
+func each(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	fn := args[1]
+	if !isCallable(fn) {
+		return newError("second argument to `each` must be FUNCTION or BUILTIN, got %s",
+			fn.Type())
+	}
+
+	var calls [][]object.Object
+	switch coll := args[0].(type) {
+	case *object.Array:
+		for _, e := range coll.Elements {
+			calls = append(calls, []object.Object{e})
+		}
+	case *object.Set:
+		for _, m := range coll.Members() {
+			calls = append(calls, []object.Object{m})
+		}
+	case *object.Hash:
+		for _, pair := range coll.SortedPairs() {
+			calls = append(calls, []object.Object{pair.Key, pair.Value})
+		}
+	case *object.Null:
+		// like an empty collection, there is nothing to call fn with
+	default:
+		return newError("first argument to `each` must be ARRAY, HASH or SET, got %s",
+			args[0].Type())
+	}
+
+	for _, callArgs := range calls {
+		if result := ev.applyFunction(fn, callArgs); isError(result) {
+			return result
+		}
+	}
+
+	return args[0]
+}
+
+// groupBy collects the elements of an array into a hash of arrays keyed by the result of calling
+// a callable on them. Each group keeps the elements in the order of the array.
+func groupBy(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	arr, fn, errObj := arrayAndCallable("groupBy", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	if errObj := ev.allocate(len(arr.Elements)); errObj != nil {
+		return errObj
+	}
+
+	groups := object.NewHash()
+	for _, e := range arr.Elements {
+		key := ev.applyFunction(fn, []object.Object{e})
+		if isError(key) {
+			return key
+		}
+
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s (%s)", key.Type(), key.Inspect())
+		}
+
+		if pair, found := groups.Pairs[hashable.HashKey()].FindPair(key); found {
+			group := pair.Value.(*object.Array)
+			group.Elements = append(group.Elements, e)
+			continue
+		}
+
+		// the key is hashable, so adding it can't fail
+		groups.Add(key, &object.Array{Elements: []object.Object{e}})
+	}
+
+	return groups
+}
+
+// partition splits an array in two, the elements a callable returns something truthy for and
+// the rest, both in the order of the array
+func partition(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	arr, fn, errObj := arrayAndCallable("partition", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	if errObj := ev.allocate(len(arr.Elements)); errObj != nil {
+		return errObj
+	}
+
+	matching, rest := []object.Object{}, []object.Object{}
+	for _, e := range arr.Elements {
+		result := ev.applyFunction(fn, []object.Object{e})
+		if isError(result) {
+			return result
+		}
+
+		if isTruthy(result) {
+			matching = append(matching, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+
+	return &object.Array{Elements: []object.Object{
+		&object.Array{Elements: matching},
+		&object.Array{Elements: rest},
+	}}
+}
+
+// count calls a callable on every element of an array, counting the truthy results
+func count(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	arr, fn, errObj := arrayAndCallable("count", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	n := int64(0)
+	for _, e := range arr.Elements {
+		result := ev.applyFunction(fn, []object.Object{e})
+		if isError(result) {
+			return result
+		}
+
+		if isTruthy(result) {
+			n++
+		}
+	}
+
+	return object.NewInteger(n)
+}
+
+// str converts its argument to a string the way puts prints it
+func str(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	s, errObj := ev.stringify(args[0])
+	if errObj != nil {
+		return errObj
+	}
+
+	return object.NewString(s)
+}
+
+// puts prints each of its arguments on a line of its own, converted the way str converts them
+func puts(ctx context.Context, args ...object.Object) object.Object {
+	ev := evaluationFrom(ctx)
+
+	for _, arg := range args {
+		s, errObj := ev.stringify(arg)
+		if errObj != nil {
+			return errObj
+		}
+		fmt.Println(s)
+	}
+
+	return NULL
+}
+
+// stringify inspects obj, except for the hashes in it with a callable under the "__str__" key:
+// such a hash stands for an object that knows how to describe itself, so the callable is called
+// with the hash and has to return the string to use, wherever the hash is nested
+func (ev *evaluation) stringify(obj object.Object) (string, *object.Error) {
+	return ev.render(obj, object.InspectWith)
+}
+
+// Stringify renders obj the way str does, with render being object.InspectWith or
+// object.PrettyInspectWith, for hosts showing values like the REPL does
+func Stringify(
+	ctx context.Context, obj object.Object, render func(object.Object, object.Describe) string,
+) (string, *object.Error) {
+	return evaluationFrom(ctx).render(obj, render)
+}
+
+func (ev *evaluation) render(
+	obj object.Object, render func(object.Object, object.Describe) string,
+) (string, *object.Error) {
+	// after the first __str__ that fails the rest aren't called, the result is thrown away anyway
+	var failed *object.Error
+	s := render(obj, func(hash *object.Hash) (string, bool) {
+		if failed != nil {
+			return "", false
+		}
+
+		s, ok, errObj := ev.describe(hash)
+		failed = errObj
+		return s, ok
+	})
+	if failed != nil {
+		return "", failed
+	}
+
+	return s, nil
+}
+
+// describe calls the "__str__" callable of hash, reporting false when it has none
+func (ev *evaluation) describe(hash *object.Hash) (string, bool, *object.Error) {
+	key := object.NewString("__str__")
+	pair, found := hash.Pairs[key.HashKey()].FindPair(key)
+	if !found || !isCallable(pair.Value) {
+		return "", false, nil
+	}
+
+	result := ev.applyFunction(pair.Value, []object.Object{hash})
+	if errObj, ok := result.(*object.Error); ok {
+		return "", false, errObj
+	}
+
+	s, ok := result.(*object.String)
+	if !ok {
+		return "", false, newError("`__str__` must return STRING, got %s", result.Type())
+	}
+
+	return s.Value, true, nil
+}
+
+// orEmpty treats null as an empty array, so the builtins reading a collection can be applied to
+// data that may be missing, such as a hash lookup of an absent key. Anything else is returned as
+// is for the caller to check.
+func orEmpty(arg object.Object) object.Object {
+	if arg == NULL {
+		return &object.Array{Elements: []object.Object{}}
+	}
+
+	return arg
+}
+
+// orEmptySet is orEmpty for the builtins reading a set, treating null as an empty one
+func orEmptySet(arg object.Object) object.Object {
+	if arg == NULL {
+		return object.NewSet()
+	}
+
+	return arg
+}
+
+// arrayAndCallable checks the arguments of the builtins that take an array and a callable to
+// call on its elements
+func arrayAndCallable(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got = %d, want = 2", len(args))
+	}
+
+	arr, ok := orEmpty(args[0]).(*object.Array)
+	if !ok {
+		return nil, nil, newError("first argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	if !isCallable(args[1]) {
+		return nil, nil, newError("second argument to `%s` must be FUNCTION or BUILTIN, got %s",
+			name, args[1].Type())
+	}
+
+	return arr, args[1], nil
+}
+
+// builtinNames lists the names of every builtin, including the ones registered by the host,
+// sorted
+func builtinNames(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got = %d, want = 0", len(args))
+	}
+
+	sorted := Builtins()
+	elements := make([]object.Object, len(sorted))
+	for i, builtin := range sorted {
+		elements[i] = object.NewString(builtin.Name)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// Builtins returns every builtin, including the ones registered by the host, sorted by name
+func Builtins() []*object.Builtin {
+	sorted := make([]*object.Builtin, 0, len(builtins))
+	for _, builtin := range builtins {
+		sorted = append(sorted, builtin)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	return sorted
+}
+
+// BuiltinDoc returns the documentation of a builtin, or a placeholder when it has none
+func BuiltinDoc(builtin *object.Builtin) string {
+	name := builtin.Name
+	if name == "" {
+		name = builtin.Inspect()
+	}
+	if builtin.Doc == "" {
+		return name + ": no documentation"
+	}
+
+	return builtin.Doc
+}
+
+// callableName names a callable in the name of a builtin wrapping it, "fn" standing for an
+// anonymous function
+func callableName(fn object.Object) string {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name != "" {
+			return fn.Name
+		}
+	case *object.Builtin:
+		if fn.Name != "" {
+			return fn.Name
+		}
+		return fn.Inspect()
+	}
+
+	return "fn"
+}
+
+// partial binds the leading arguments of a callable, returning a builtin that applies it
+// to the bound arguments followed by the ones it's called with
+func partial(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got = %d, want at least 1",
+			len(args))
+	}
+
+	fn := args[0]
+	if !isCallable(fn) {
+		return newError("first argument to `partial` must be FUNCTION or BUILTIN, got %s",
+			fn.Type())
+	}
+
+	bound := make([]object.Object, len(args)-1)
+	copy(bound, args[1:])
+
+	callArgs := []string{}
+	for _, arg := range bound {
+		callArgs = append(callArgs, arg.Inspect())
+	}
+	callArgs = append(callArgs, "args...")
+
+	name := "partial(" + callableName(fn) + ")"
+	return &object.Builtin{
+		Name: name,
+		Doc:  name + "(args...) -> any: " + callableName(fn) + "(" + strings.Join(callArgs, ", ") + ")",
+		FnContext: func(ctx context.Context, rest ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			combined := make([]object.Object, 0, len(bound)+len(rest))
+			combined = append(combined, bound...)
+			combined = append(combined, rest...)
+			return ev.applyFunction(fn, combined)
+		},
+	}
+}
+
+// compose chains callables right to left: compose(f, g, h)(x) is f(g(h(x))). The rightmost
+// callable receives all the arguments, every other one receives the previous result.
+func compose(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got = %d, want at least 1",
+			len(args))
+	}
+
+	for i, fn := range args {
+		if !isCallable(fn) {
+			return newError("argument %d to `compose` must be FUNCTION or BUILTIN, got %s",
+				i+1, fn.Type())
+		}
+	}
+
+	fns := make([]object.Object, len(args))
+	copy(fns, args)
+
+	names := make([]string, len(fns))
+	call := "args..."
+	for i := len(fns) - 1; i >= 0; i-- {
+		names[i] = callableName(fns[i])
+		call = names[i] + "(" + call + ")"
+	}
+
+	name := "compose(" + strings.Join(names, ", ") + ")"
+	return &object.Builtin{
+		Name: name,
+		Doc:  name + "(args...) -> any: " + call,
+		FnContext: func(ctx context.Context, callArgs ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			result := ev.applyFunction(fns[len(fns)-1], callArgs)
+			for i := len(fns) - 2; i >= 0; i-- {
+				if isError(result) {
+					return result
+				}
+				result = ev.applyFunction(fns[i], []object.Object{result})
+			}
+			return result
+		},
+	}
+}
+
+// memoize wraps a callable in a builtin caching its results. The cache is a tree of hashes keyed
+// by the number of arguments and then by each argument in turn, the last level holding the
+// results. Calls with an argument that isn't hashable go straight to the callable, and errors
+// aren't cached.
+func memoize(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got = %d, want = 1", len(args))
+	}
+
+	fn := args[0]
+	if !isCallable(fn) {
+		return newError("argument to `memoize` must be FUNCTION or BUILTIN, got %s", fn.Type())
+	}
+
+	cache := object.NewHash()
+
+	name := "memoize(" + callableName(fn) + ")"
+	return &object.Builtin{
+		Name: name,
+		Doc: name + "(args...) -> any: " + callableName(fn) +
+			"(args...), remembered for hashable arguments",
+		FnContext: func(ctx context.Context, callArgs ...object.Object) object.Object {
+			ev := evaluationFrom(ctx)
+
+			keys := append([]object.Object{object.NewInteger(int64(len(callArgs)))}, callArgs...)
+			for _, key := range keys {
+				if _, ok := key.(object.Hashable); !ok {
+					return ev.applyFunction(fn, callArgs)
+				}
+			}
+
+			level := cache
+			for _, key := range keys[:len(keys)-1] {
+				next, ok := cachedValue(level, key)
+				if !ok {
+					next = object.NewHash()
+					level.Add(key, next)
+				}
+				level = next.(*object.Hash)
+			}
+
+			last := keys[len(keys)-1]
+			if result, ok := cachedValue(level, last); ok {
+				return result
+			}
+
+			result := ev.applyFunction(fn, callArgs)
+			if isError(result) {
+				return result
+			}
+
+			if errObj := ev.allocate(1); errObj != nil {
+				return errObj
+			}
+			level.Add(last, result)
+
+			return result
+		},
+	}
+}
+
+// cachedValue looks up a hashable key in one level of memoize's cache
+func cachedValue(level *object.Hash, key object.Object) (object.Object, bool) {
+	pair, ok := level.Pairs[key.(object.Hashable).HashKey()].FindPair(key)
+	return pair.Value, ok
+}
+
+// generator calls a callable lazily on a goroutine of its own, producing the values it yields
+func generator(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got = %d, want at least 1", len(args))
+	}
+
+	fn := args[0]
+	if !isCallable(fn) {
+		return newError("first argument to `generator` must be FUNCTION or BUILTIN, got %s",
+			fn.Type())
+	}
+
+	callArgs := make([]object.Object, len(args)-1)
+	copy(callArgs, args[1:])
+
+	return newGenerator(fn, callArgs)
 }
@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"context"
+	"github.com/kahvecikaan/monkey-lang/ast"
+	"github.com/kahvecikaan/monkey-lang/object"
+)
+
+// evaluation is the state of one evaluation, started by Eval, EvalContext or CallFunction and
+// passed along to everything it evaluates, so evaluations running at the same time only share the
+// values they're given. It's also the context builtins are called with: a builtin calling back
+// into Monkey code continues the evaluation calling it, see evaluationFrom.
+type evaluation struct {
+	context.Context
+	*budget
+
+	// generator is the generator whose function runs on this evaluation's goroutine, the one a
+	// yield hands its value to, nil outside of generators
+	generator *generatorRun
+	// depth counts the function calls in progress on this evaluation's goroutine
+	depth int
+}
+
+// budget is the part of an evaluation its generators share with it while they run: the limits
+// it was started with and what counts against them, along with how it evaluates
+type budget struct {
+	// stepLimit caps the number of nodes evaluated, 0 means no limit
+	stepLimit int
+	steps     int
+	// allocationLimit caps the sizes added up by allocate, 0 means no limit
+	allocationLimit int
+	allocated       int
+	// wrapIntegers makes integer arithmetic wrap around instead of failing on overflow
+	wrapIntegers bool
+	// profile records the calls and steps of the evaluation, nil when it isn't profiled
+	profile *Profile
+}
+
+type (
+	evaluationKey      struct{}
+	stepLimitKey       struct{}
+	allocationLimitKey struct{}
+	integerWrappingKey struct{}
+	profileKey         struct{}
+)
+
+// WithStepLimit returns a copy of ctx making EvalContext stop with a "step limit exceeded" error
+// after evaluating limit AST nodes. A limit of 0 or less means no limit, which is the default.
+func WithStepLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, stepLimitKey{}, limit)
+}
+
+// WithAllocationLimit returns a copy of ctx making EvalContext stop with an "allocation limit
+// exceeded" error once the array elements, hash pairs, set members and string bytes it has
+// created add up to more than limit. Everything created counts, even values that are no longer
+// referenced. A limit of 0 or less means no limit, which is the default.
+func WithAllocationLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, allocationLimitKey{}, limit)
+}
+
+// WithIntegerWrapping returns a copy of ctx making integer arithmetic in EvalContext wrap around
+// the way Go's int64 does, instead of stopping with an "integer overflow" error, which is the
+// default.
+func WithIntegerWrapping(ctx context.Context) context.Context {
+	return context.WithValue(ctx, integerWrappingKey{}, true)
+}
+
+// WithProfile returns a copy of ctx making EvalContext record in profile how many nodes it
+// evaluates, and how many calls are made to each function and nodes evaluated in its body.
+// Profiling is off by default.
+func WithProfile(ctx context.Context, profile *Profile) context.Context {
+	return context.WithValue(ctx, profileKey{}, profile)
+}
+
+// EvalContext evaluates node like Eval, but stops with an "evaluation canceled" error once ctx
+// is done. Cancellation is checked when the evaluation starts and before every function call,
+// which is where a runaway script spends its time.
+//
+// Every call is an evaluation of its own, with its own limits, so EvalContext can be called from
+// several goroutines at once, as long as they don't share environments or values they change. The
+// exception is the context a builtin registered with RegisterBuiltinContext is called with, or
+// one derived from it: EvalContext then continues the evaluation calling the builtin, within its
+// limits.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	ev := evaluationFrom(ctx)
+	if errObj := ev.checkCanceled(); errObj != nil {
+		return errObj
+	}
+
+	return ev.eval(node, env)
+}
+
+// newEvaluation starts an evaluation with the limits and settings ctx was given
+func newEvaluation(ctx context.Context) *evaluation {
+	b := &budget{}
+	b.stepLimit, _ = ctx.Value(stepLimitKey{}).(int)
+	b.allocationLimit, _ = ctx.Value(allocationLimitKey{}).(int)
+	b.wrapIntegers, _ = ctx.Value(integerWrappingKey{}).(bool)
+	b.profile, _ = ctx.Value(profileKey{}).(*Profile)
+
+	return &evaluation{Context: ctx, budget: b}
+}
+
+// evaluationFrom returns the evaluation ctx belongs to: ctx itself when it's the one a builtin was
+// called with, the evaluation it was derived from, or a new evaluation when there's none
+func evaluationFrom(ctx context.Context) *evaluation {
+	if ev, ok := ctx.(*evaluation); ok {
+		return ev
+	}
+
+	if outer, ok := ctx.Value(evaluationKey{}).(*evaluation); ok {
+		// the derived context may be done sooner, but it spends the same budget
+		return &evaluation{Context: ctx, budget: outer.budget, generator: outer.generator, depth: outer.depth}
+	}
+
+	return newEvaluation(ctx)
+}
+
+// Value makes the evaluation findable from the contexts derived from it
+func (ev *evaluation) Value(key interface{}) interface{} {
+	if key == (evaluationKey{}) {
+		return ev
+	}
+
+	return ev.Context.Value(key)
+}
+
+// countStep counts the evaluation of one node against the step limit, and in the profile
+func (ev *evaluation) countStep() *object.Error {
+	if ev.profile != nil {
+		ev.profile.countStep()
+	}
+
+	if ev.stepLimit <= 0 {
+		return nil
+	}
+
+	ev.steps++
+	if ev.steps > ev.stepLimit {
+		return newError("step limit exceeded: %d", ev.stepLimit)
+	}
+
+	return nil
+}
+
+// allocate counts a new value of the given size against the allocation limit. Everything that
+// builds an array, hash, set or string whose size depends on the script calls it first.
+func (ev *evaluation) allocate(size int) *object.Error {
+	if ev.allocationLimit <= 0 {
+		return nil
+	}
+
+	// compared before adding, so a huge size can't overflow allocated
+	if size > ev.allocationLimit-ev.allocated {
+		return newError("allocation limit exceeded: %d", ev.allocationLimit)
+	}
+	ev.allocated += size
+
+	return nil
+}
+
+func (ev *evaluation) checkCanceled() *object.Error {
+	if ev.Err() != nil {
+		return newError("evaluation canceled")
+	}
+
+	return nil
+}
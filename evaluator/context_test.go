@@ -0,0 +1,181 @@
+package evaluator
+
+import (
+	"context"
+	"github.com/kahvecikaan/monkey-lang/lexer"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"github.com/kahvecikaan/monkey-lang/parser"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEvalContext(ctx context.Context, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return EvalContext(ctx, program, env)
+}
+
+func TestEvalContext(t *testing.T) {
+	testIntegerObject(t, testEvalContext(context.Background(), "let f = fn(x) { x * 2 }; f(2)"), 4)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []string{
+		"1 + 2",
+		"let f = fn(x) { x }; f(1)",
+		"len([1, 2])",
+	}
+
+	for _, input := range tests {
+		testCanceled(t, testEvalContext(canceled, input))
+	}
+
+	// a context ending during the evaluation stops it at the next call
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	input := `
+let loop = fn(n) { loop(n + 1) };
+loop(0);
+`
+	testCanceled(t, testEvalContext(ctx, input))
+
+	// and within a builtin filling an array
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	testCanceled(t, testEvalContext(ctx, "fill(9223372036854775807, 0)"))
+
+	// the context only applies while EvalContext runs
+	testIntegerObject(t, testEval("let f = fn(x) { x * 2 }; f(2)"), 4)
+}
+
+func testCanceled(t *testing.T, obj object.Object) {
+	t.Helper()
+
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("no error object returned. got = %T (%+v)", obj, obj)
+		return
+	}
+	if errObj.Message != "evaluation canceled" {
+		t.Errorf("wrong error message. got = %q", errObj.Message)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	ctx := WithStepLimit(context.Background(), 100)
+
+	testIntegerObject(t, testEvalContext(ctx, "let f = fn(x) { x * 2 }; f(2)"), 4)
+
+	tests := []string{
+		"let loop = fn(n) { loop(n + 1) }; loop(0);",
+		"let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(1000)",
+		"fill(9223372036854775807, 0)",
+	}
+
+	for _, input := range tests {
+		evaluated := testEvalContext(ctx, input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got = %T (%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "step limit exceeded: 100" {
+			t.Errorf("wrong error message for %q. got = %q", input, errObj.Message)
+		}
+	}
+
+	// every evaluation gets the full budget
+	for i := 0; i < 3; i++ {
+		testIntegerObject(t, testEvalContext(ctx, "let f = fn(x) { x * 2 }; f(2)"), 4)
+	}
+
+	// no limit unless one is set
+	input := "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(1000)"
+	testIntegerObject(t, testEvalContext(context.Background(), input), 1000)
+	testIntegerObject(t, testEvalContext(WithStepLimit(context.Background(), 0), input), 1000)
+	testIntegerObject(t, testEval(input), 1000)
+}
+
+func TestAllocationLimit(t *testing.T) {
+	ctx := WithAllocationLimit(context.Background(), 100)
+
+	testIntegerObject(t, testEvalContext(ctx, "len([1, 2, 3] |> push(4))"), 4)
+
+	tests := []string{
+		// each push copies the whole array
+		"let grow = fn(arr) { grow(push(arr, 1)) }; grow([])",
+		// each concatenation doubles the string
+		`let grow = fn(s) { grow(s + s) }; grow("ab")`,
+		"let grow = fn(s) { grow(add(s, size(s))) }; grow(set([]))",
+		"let grow = fn(n, acc) { grow(n + 1, {n: acc}) }; grow(0, {})",
+		"let grow = fn(n, acc) { grow(n + 1, [n, acc, n]) }; grow(0, [])",
+		"fill(1000, 0)",
+		"fill(100000000000, 0)",
+		"times(1000, fn(i) { i })",
+		"let grow = fn(arr) { grow(concat(arr, arr, [1])) }; grow([])",
+		// 120 bytes
+		`base64Decode("` + strings.Repeat("YWFh", 40) + `")`,
+	}
+
+	for _, input := range tests {
+		evaluated := testEvalContext(ctx, input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got = %T (%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "allocation limit exceeded: 100" {
+			t.Errorf("wrong error message for %q. got = %q", input, errObj.Message)
+		}
+	}
+
+	// no limit unless one is set
+	input := "let build = fn(n, arr) { if (n == 0) { arr } else { build(n - 1, push(arr, n)) } }; len(build(200, []))"
+	testIntegerObject(t, testEvalContext(context.Background(), input), 200)
+	testIntegerObject(t, testEval(input), 200)
+}
+
+func TestAllocateDoesNotOverflow(t *testing.T) {
+	ev := newEvaluation(WithAllocationLimit(context.Background(), 100))
+	ev.allocated = 50
+	if errObj := ev.allocate(math.MaxInt); errObj == nil {
+		t.Fatalf("allocating math.MaxInt succeeded")
+	}
+	if errObj := ev.allocate(50); errObj != nil {
+		t.Errorf("allocating up to the limit failed: %s", errObj.Message)
+	}
+	if errObj := ev.allocate(1); errObj == nil {
+		t.Errorf("allocating past the limit succeeded")
+	}
+}
+
+func TestIntegerWrapping(t *testing.T) {
+	ctx := WithIntegerWrapping(context.Background())
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807 + 1", math.MinInt64},
+		{"-9223372036854775808 - 1", math.MaxInt64},
+		{"4611686018427387904 * 2", math.MinInt64},
+		{"-9223372036854775808 / -1", math.MinInt64},
+		{"let x = -9223372036854775808; -x", math.MinInt64},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEvalContext(ctx, tt.input), tt.expected)
+	}
+
+	// wrapping only applies while EvalContext runs
+	if _, ok := testEval("9223372036854775807 + 1").(*object.Error); !ok {
+		t.Errorf("overflow outside of EvalContext didn't fail")
+	}
+}
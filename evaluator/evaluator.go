@@ -1,110 +1,197 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"github.com/kahvecikaan/monkey-lang/ast"
 	"github.com/kahvecikaan/monkey-lang/object"
+	"github.com/kahvecikaan/monkey-lang/token"
+	"math"
+	"math/big"
+	"unicode/utf8"
 )
 
 var (
-	NULL  = &object.Null{}
+	NULL  = object.NULL
 	TRUE  = object.TRUE
 	FALSE = object.FALSE
 )
 
+// Eval evaluates node in env. Errors are located at the innermost node they come out of, so they
+// point at the operator, identifier or call that failed rather than the statement around it. Each
+// call is an evaluation of its own, like one EvalContext runs with a context that's never done.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return newEvaluation(context.Background()).eval(node, env)
+}
+
+// eval is Eval for the evaluator's own recursion, continuing the evaluation ev
+func (ev *evaluation) eval(node ast.Node, env *object.Environment) object.Object {
+	if errObj := ev.countStep(); errObj != nil {
+		return errObj
+	}
+
+	result := ev.evalNode(node, env)
+	if errObj, ok := result.(*object.Error); ok && errObj.Line == 0 {
+		tok := nodeToken(node)
+		errObj.Line, errObj.Column = tok.Line, tok.Column
+	}
+
+	return result
+}
+
+func (ev *evaluation) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		return ev.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return ev.eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return ev.evalBlockStatement(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
-			return val
-		}
-		return &object.ReturnValue{Value: val}
+		return ev.evalReturn(node.ReturnValue, env)
+	case *ast.GuardStatement:
+		return ev.evalGuardStatement(node, env)
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := ev.eval(node.Value, env)
+		if isErrorOrReturn(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		// let _ = x evaluates x for its effects only
+		if !node.Name.IsBlank() {
+			env.Set(node.Name.Value, val)
+		}
 
 	// Expressions
 	case *ast.IntegerLiteral:
 		return object.NewInteger(node.Value)
+	case *ast.FloatLiteral:
+		return object.NewFloat(node.Value)
 	case *ast.StringLiteral:
 		return object.NewString(node.Value)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		if literal, ok := node.Right.(*ast.IntegerLiteral); ok && node.Operator == "-" &&
+			literal.Value == math.MinInt64 {
+			// the parser stores -9223372036854775808 negated already, see parseIntegerLiteral
+			return object.NewInteger(math.MinInt64)
+		}
+
+		right := ev.eval(node.Right, env)
+		if isErrorOrReturn(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return ev.evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			return ev.evalLogicalExpression(node, env)
+		}
+		if node.Operator == "|>" {
+			return ev.evalPipeExpression(node, env)
+		}
+		left := ev.eval(node.Left, env)
+		if isErrorOrReturn(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := ev.eval(node.Right, env)
+		if isErrorOrReturn(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return ev.evalInfixExpression(node.Operator, left, right)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return ev.evalIfExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
+	case *ast.YieldExpression:
+		value := ev.eval(node.Value, env)
+		if isErrorOrReturn(value) {
+			return value
+		}
+		return ev.evalYield(value)
+	case *ast.DoExpression:
+		// a return inside the block isn't unwrapped, so it goes on to end the enclosing function
+		return ev.eval(node.Body, object.NewEnclosedEnvironment(env))
+	case *ast.WithExpression:
+		return ev.evalWithExpression(node, env)
+	case *ast.ReturnExpression:
+		return ev.evalReturn(node.ReturnValue, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, Name: node.Name}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
+		function := ev.eval(node.Function, env)
+		if isErrorOrReturn(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := ev.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isErrorOrReturn(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return ev.applyFunction(function, args)
+	case *ast.MethodCallExpression:
+		return ev.evalMethodCallExpression(node, env)
+	case *ast.EnumStatement:
+		tags := make([]string, len(node.Members))
+		for i, m := range node.Members {
+			tags[i] = m.Value
+		}
+		env.Set(node.Name.Value, object.NewEnum(node.Name.Value, tags))
+	case *ast.MemberExpression:
+		return ev.evalMemberExpression(node, env)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := ev.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isErrorOrReturn(elements[0]) {
 			return elements[0]
 		}
+		if errObj := ev.allocate(len(elements)); errObj != nil {
+			return errObj
+		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := ev.eval(node.Left, env)
+		if isErrorOrReturn(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		index := ev.eval(node.Index, env)
+		if isErrorOrReturn(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return ev.evalSliceExpression(node, env)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return ev.evalHashLiteral(node, env)
 	}
 
 	return nil
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
-	var result object.Object
+// evalProgram recovers from panics in the evaluator, turning them into an "internal error" that
+// says which statement was being evaluated, so a bug can't take down the REPL or the host.
+func (ev *evaluation) evalProgram(program *ast.Program, env *object.Environment) (result object.Object) {
+	if len(program.Statements) == 0 {
+		return NULL
+	}
+
+	var current ast.Statement
+	defer func() {
+		if r := recover(); r != nil {
+			// the statement itself may be malformed, so it's only located, not printed
+			tok := nodeToken(current)
+			result = newError("internal error: %v (in %s statement at line %d, column %d)",
+				r, tok.Type, tok.Line, tok.Column)
+		}
+	}()
 
 	for _, stmt := range program.Statements {
-		result = Eval(stmt, env)
+		current = stmt
+		result = ev.eval(stmt, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -117,11 +204,77 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+// nodeToken returns the token errors coming out of node are located at, the zero token when it's
+// unknown. That's the token a statement starts with, and an expression's own token, such as the
+// operator of an infix expression, except that calls are located at what they call.
+func nodeToken(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.GuardStatement:
+		return node.Token
+	case *ast.EnumStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.Identifier:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.FloatLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.DoExpression:
+		return node.Token
+	case *ast.WithExpression:
+		return node.Token
+	case *ast.ReturnExpression:
+		return node.Token
+	case *ast.YieldExpression:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.CallExpression:
+		return nodeToken(node.Function)
+	case *ast.MethodCallExpression:
+		return node.Method.Token
+	case *ast.MemberExpression:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.SliceExpression:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	default:
+		return token.Token{}
+	}
+}
+
+// evalBlockStatement evaluates to the value of the block's last statement, the same way a program
+// does, so function bodies and if branches don't need an explicit return. An empty block or one
+// ending in a let statement evaluates to NULL. A ReturnValue or Error stops the block and is passed
+// on still wrapped, so that it unwinds through enclosing blocks up to the function call or program.
+func (ev *evaluation) evalBlockStatement(
+	block *ast.BlockStatement, env *object.Environment,
+) object.Object {
 	var result object.Object
 
 	for _, stmt := range block.Statements {
-		result = Eval(stmt, env)
+		result = ev.eval(stmt, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -131,6 +284,10 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 		}
 	}
 
+	if result == nil {
+		return NULL
+	}
+
 	return result
 }
 
@@ -138,83 +295,218 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return object.GetBooleanObject(input)
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func (ev *evaluation) evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusOperatorExpression(right)
+		return ev.evalMinusOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
-// evalBangOperatorExpression treats any non-boolean, non-null value as truthy, so it returns FALSE when negating it
+// evalBangOperatorExpression negates the truthiness of right, so any non-boolean, non-null value becomes FALSE
 func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
-	}
+	return nativeBoolToBooleanObject(!isTruthy(right))
 }
 
-func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+func (ev *evaluation) evalMinusOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 && !ev.wrapIntegers {
+			return newError("integer overflow in '-(%d)'", right.Value)
+		}
+		return object.NewInteger(-right.Value)
+	case *object.Float:
+		return object.NewFloat(-right.Value)
+	case *object.BigInt:
+		return object.NewBigInt(new(big.Int).Neg(right.Value))
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return object.NewInteger(-value)
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func (ev *evaluation) evalInfixExpression(operator string, left, right object.Object) object.Object {
+	if errObj := checkDivisor(operator, left, right); errObj != nil {
+		return errObj
+	}
+
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return ev.evalIntegerInfixExpression(operator, left, right)
+	case isIntegral(left) && isIntegral(right):
+		return evalBigIntInfixExpression(operator, left, right)
+	case isNumeric(left) && isNumeric(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return ev.evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ &&
+		(operator == "<" || operator == ">"):
+		return ev.evalArrayComparison(operator, left.(*object.Array), right.(*object.Array))
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right) // pointer check works for TRUE, FALSE and NULL but not Integers
+		// pointer check works for TRUE, FALSE and NULL but not Integers, and compares functions
+		// and builtins by identity
+		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right) // same pointer check here because they reference the same obj
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
-	case operator == "&&":
-		// Short circuit: if left is false, don't evaluate right
-		if !isTruthy(left) {
-			return FALSE
-		}
-		return nativeBoolToBooleanObject(isTruthy(right))
-	case operator == "||":
-		// Short circuit: if left is true, don't evaluate right
-		if isTruthy(left) {
-			return TRUE
-		}
-		return nativeBoolToBooleanObject(isTruthy(right))
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+// evalLogicalExpression evaluates && and || with short-circuiting: the right operand is only
+// evaluated when the left one doesn't already decide the result
+func (ev *evaluation) evalLogicalExpression(
+	node *ast.InfixExpression, env *object.Environment,
+) object.Object {
+	left := ev.eval(node.Left, env)
+	if isErrorOrReturn(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := ev.eval(node.Right, env)
+	if isErrorOrReturn(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// evalPipeExpression calls the right operand with the left one as its first argument:
+// x |> f is f(x) and x |> f(1, 2) is f(x, 1, 2)
+func (ev *evaluation) evalPipeExpression(
+	node *ast.InfixExpression, env *object.Environment,
+) object.Object {
+	left := ev.eval(node.Left, env)
+	if isErrorOrReturn(left) {
+		return left
+	}
+
+	args := []object.Object{left}
+	fnNode := node.Right
+
+	if call, ok := node.Right.(*ast.CallExpression); ok {
+		fnNode = call.Function
+		rest := ev.evalExpressions(call.Arguments, env)
+		if len(rest) == 1 && isErrorOrReturn(rest[0]) {
+			return rest[0]
+		}
+		args = append(args, rest...)
+	}
+
+	function := ev.eval(fnNode, env)
+	if isErrorOrReturn(function) {
+		return function
+	}
+
+	return ev.applyFunction(function, args)
+}
+
+func (ev *evaluation) evalIntegerInfixExpression(
+	operator string, left, right object.Object,
+) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 	switch operator {
+	case "+", "-", "*", "/", "%":
+		result, overflowed := integerArithmetic(operator, leftVal, rightVal)
+		if overflowed && !ev.wrapIntegers {
+			return newError("integer overflow in '%d %s %d'", leftVal, operator, rightVal)
+		}
+		return object.NewInteger(result)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// integerArithmetic applies an arithmetic operator the way Go does, wrapping around on
+// overflow, and reports whether it did
+func integerArithmetic(operator string, a, b int64) (int64, bool) {
+	switch operator {
+	case "+":
+		sum := a + b
+		return sum, (a > 0 && b > 0 && sum < 0) || (a < 0 && b < 0 && sum >= 0)
+	case "-":
+		diff := a - b
+		return diff, (a >= 0 && b < 0 && diff < 0) || (a < 0 && b > 0 && diff >= 0)
+	case "*":
+		product := a * b
+		if a == 0 || b == 0 {
+			return 0, false
+		}
+		return product, product/b != a || (a == -1 && b == math.MinInt64) ||
+			(b == -1 && a == math.MinInt64)
+	case "/":
+		// only math.MinInt64 / -1 overflows
+		return a / b, a == math.MinInt64 && b == -1
+	default:
+		return a % b, false
+	}
+}
+
+// checkDivisor is the one place dividing by zero is caught, for / and % on every kind of number
+func checkDivisor(operator string, left, right object.Object) *object.Error {
+	if operator != "/" && operator != "%" {
+		return nil
+	}
+	if !isNumber(left) || !isNumber(right) {
+		return nil
+	}
+
+	var zero bool
+	switch right := right.(type) {
+	case *object.Integer:
+		zero = right.Value == 0
+	case *object.Float:
+		zero = right.Value == 0
+	case *object.BigInt:
+		zero = right.Value.Sign() == 0
+	}
+
+	if zero {
+		return newError("division by zero")
+	}
+
+	return nil
+}
+
+// evalFloatInfixExpression handles arithmetic where at least one operand is a float,
+// promoting the other operand to a float first
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch operator {
 	case "+":
-		return object.NewInteger(leftVal + rightVal)
+		return object.NewFloat(leftVal + rightVal)
 	case "-":
-		return object.NewInteger(leftVal - rightVal)
+		return object.NewFloat(leftVal - rightVal)
 	case "*":
-		return object.NewInteger(leftVal * rightVal)
+		return object.NewFloat(leftVal * rightVal)
 	case "/":
-		return object.NewInteger(leftVal / rightVal)
+		return object.NewFloat(leftVal / rightVal)
+	case "%":
+		return object.NewFloat(math.Mod(leftVal, rightVal))
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -229,7 +521,73 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+// evalBigIntInfixExpression handles arithmetic where at least one operand is a bigint and the
+// other one an integer, promoting the integer first. Results stay bigints even when they'd fit an
+// integer.
+func evalBigIntInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+	switch operator {
+	case "+":
+		return object.NewBigInt(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return object.NewBigInt(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return object.NewBigInt(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		// Quo and Rem truncate like integer division does
+		return object.NewBigInt(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		return object.NewBigInt(new(big.Int).Rem(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// isNumber reports whether obj is any kind of number
+func isNumber(obj object.Object) bool {
+	return isNumeric(obj) || obj.Type() == object.BIGINT_OBJ
+}
+
+// isIntegral reports whether obj is an integer or a bigint
+func isIntegral(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIGINT_OBJ
+}
+
+// toBigInt assumes obj has already been checked with isIntegral
+func toBigInt(obj object.Object) *big.Int {
+	if integer, ok := obj.(*object.Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+
+	return obj.(*object.BigInt).Value
+}
+
+func isNumeric(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat assumes obj has already been checked with isNumeric
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+
+	return obj.(*object.Float).Value
+}
+
+func (ev *evaluation) evalStringInfixExpression(
+	operator string, left, right object.Object,
+) object.Object {
 	if operator != "+" {
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -237,24 +595,118 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
+	if errObj := ev.allocate(len(leftVal) + len(rightVal)); errObj != nil {
+		return errObj
+	}
 	return object.NewString(leftVal + rightVal)
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+// evalArrayComparison orders arrays lexicographically: the first elements that aren't equal decide,
+// compared with the same operator so nested arrays are ordered the same way, and when one array is
+// a prefix of the other the shorter one comes first. Every pair of elements at the same index is
+// checked, not just the one deciding: unless the two are equal, they must both be numbers or both
+// arrays ordered the same way, so [1, "a"] < [2, 1] is an error like [1, "a"] < [1, 2] is.
+func (ev *evaluation) evalArrayComparison(operator string, left, right *object.Array) object.Object {
+	var decided object.Object
+	for i := 0; i < len(left.Elements) && i < len(right.Elements); i++ {
+		l, r := left.Elements[i], right.Elements[i]
+		if objectsEqual(l, r) {
+			continue
+		}
+
+		var result object.Object
+		switch {
+		case isNumber(l) && isNumber(r):
+			result = ev.evalInfixExpression(operator, l, r)
+		case l.Type() == object.ARRAY_OBJ && r.Type() == object.ARRAY_OBJ:
+			result = ev.evalArrayComparison(operator, l.(*object.Array), r.(*object.Array))
+			if isError(result) {
+				return result
+			}
+		default:
+			// the error the pair gets compared on its own
+			return ev.evalInfixExpression(operator, l, r)
+		}
+
+		if decided == nil {
+			decided = result
+		}
+	}
+	if decided != nil {
+		return decided
+	}
+
+	if operator == "<" {
+		return nativeBoolToBooleanObject(len(left.Elements) < len(right.Elements))
+	}
+	return nativeBoolToBooleanObject(len(left.Elements) > len(right.Elements))
+}
+
+func (ev *evaluation) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := ev.eval(ie.Condition, env)
+	if isErrorOrReturn(condition) {
 		return condition
 	}
 
+	// each branch gets its own environment, like a do block, so its lets don't outlive it
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return ev.eval(ie.Consequence, object.NewEnclosedEnvironment(env))
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return ev.eval(ie.Alternative, object.NewEnclosedEnvironment(env))
 	} else {
 		return NULL
 	}
 }
 
+// evalReturn wraps the value of a return statement or expression, null for a bare return, to
+// carry it out of the function
+func (ev *evaluation) evalReturn(value ast.Expression, env *object.Environment) object.Object {
+	if value == nil {
+		return &object.ReturnValue{Value: NULL}
+	}
+
+	val := ev.eval(value, env)
+	if isErrorOrReturn(val) {
+		return val
+	}
+	return &object.ReturnValue{Value: val}
+}
+
+// evalGuardStatement has no value when the condition holds, so the function carries on. When it
+// doesn't, the else block's value is returned, unless the block returns something itself.
+func (ev *evaluation) evalGuardStatement(gs *ast.GuardStatement, env *object.Environment) object.Object {
+	condition := ev.eval(gs.Condition, env)
+	if isErrorOrReturn(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return nil
+	}
+
+	evaluated := ev.eval(gs.Alternative, object.NewEnclosedEnvironment(env))
+	if isErrorOrReturn(evaluated) {
+		return evaluated
+	}
+	return &object.ReturnValue{Value: evaluated}
+}
+
+// evalWithExpression binds the names in an environment the block then runs in. A return inside the
+// block is left wrapped, so it ends the enclosing function as it would outside the with.
+func (ev *evaluation) evalWithExpression(we *ast.WithExpression, env *object.Environment) object.Object {
+	scope := object.NewEnclosedEnvironment(env)
+	for i, name := range we.Names {
+		val := ev.eval(we.Values[i], scope)
+		if isErrorOrReturn(val) {
+			return val
+		}
+		if !name.IsBlank() {
+			scope.Set(name.Value, val)
+		}
+	}
+
+	return ev.eval(we.Body, scope)
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -264,15 +716,15 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError("identifier not found: %s", node.Value)
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (ev *evaluation) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		evaluated := ev.eval(e, env)
+		if isErrorOrReturn(evaluated) {
 			return []object.Object{evaluated}
 		}
 
@@ -282,6 +734,51 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+// evalMethodCallExpression rewrites receiver.method(args) into method(receiver, args), where
+// method is looked up like any other identifier
+func (ev *evaluation) evalMethodCallExpression(
+	node *ast.MethodCallExpression, env *object.Environment,
+) object.Object {
+	receiver := ev.eval(node.Receiver, env)
+	if isErrorOrReturn(receiver) {
+		return receiver
+	}
+
+	function := evalIdentifier(node.Method, env)
+	if isErrorOrReturn(function) {
+		return function
+	}
+
+	rest := ev.evalExpressions(node.Arguments, env)
+	if len(rest) == 1 && isErrorOrReturn(rest[0]) {
+		return rest[0]
+	}
+
+	args := append([]object.Object{receiver}, rest...)
+	return ev.applyFunction(function, args)
+}
+
+func (ev *evaluation) evalMemberExpression(
+	node *ast.MemberExpression, env *object.Environment,
+) object.Object {
+	obj := ev.eval(node.Object, env)
+	if isErrorOrReturn(obj) {
+		return obj
+	}
+
+	enum, ok := obj.(*object.Enum)
+	if !ok {
+		return newError("member access not supported: %s", obj.Type())
+	}
+
+	member, ok := enum.Member(node.Member.Value)
+	if !ok {
+		return newError("unknown member of enum %s: %s", enum.Name, node.Member.Value)
+	}
+
+	return member
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -305,26 +802,155 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func evalHashLiteral(
+// evalSliceExpression slices arrays and strings (by bytes) the way Python does: negative bounds
+// count from the end, bounds out of range are clamped, and a negative step walks backwards from
+// the end, so arr[::-1] reverses arr. A string with non-ASCII characters only takes a step of 1.
+func (ev *evaluation) evalSliceExpression(
+	node *ast.SliceExpression, env *object.Environment,
+) object.Object {
+	left := ev.eval(node.Left, env)
+	if isErrorOrReturn(left) {
+		return left
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(len(left.Value))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	bounds := [3]*int64{}
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+
+		val := ev.eval(exp, env)
+		if isErrorOrReturn(val) {
+			return val
+		}
+
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError("slice bounds must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return newError("slice step cannot be zero")
+	}
+	if str, ok := left.(*object.String); ok && step != 1 && !isASCII(str.Value) {
+		// strings are sliced by bytes, so any other step would pull multibyte characters apart
+		return newError("slice step must be 1 for a string with non-ASCII characters, got %d", step)
+	}
+
+	indices := sliceIndices(length, bounds[0], bounds[1], step)
+	if errObj := ev.allocate(len(indices)); errObj != nil {
+		return errObj
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, len(indices))
+		for i, idx := range indices {
+			elements[i] = left.Elements[idx]
+		}
+		return &object.Array{Elements: elements}
+	default:
+		str := left.(*object.String).Value
+		bytes := make([]byte, len(indices))
+		for i, idx := range indices {
+			bytes[i] = str[idx]
+		}
+		return object.NewString(string(bytes))
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sliceIndices returns the indices selected by a slice of a sequence of the given length, nil
+// start and end meaning the bound was omitted
+func sliceIndices(length int64, start, end *int64, step int64) []int64 {
+	// with a negative step, -1 stands for "before the first element"
+	lower, upper := int64(0), length
+	if step < 0 {
+		lower, upper = -1, length-1
+	}
+
+	clamp := func(bound *int64, omitted int64) int64 {
+		if bound == nil {
+			return omitted
+		}
+
+		b := *bound
+		if b < 0 {
+			b += length
+			if b < lower {
+				b = lower
+			}
+		} else if b > upper {
+			b = upper
+		}
+		return b
+	}
+
+	indices := []int64{}
+	if step > 0 {
+		for i := clamp(start, lower); i < clamp(end, upper); i += step {
+			indices = append(indices, i)
+		}
+	} else {
+		for i := clamp(start, upper); i > clamp(end, lower); i += step {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
+func (ev *evaluation) evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
 	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
+	// pairs are evaluated in source order, so when a key is repeated the last value wins
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
+
+		key := ev.eval(keyNode, env)
+		if isErrorOrReturn(key) {
 			return key
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := ev.eval(valueNode, env)
+		if isErrorOrReturn(value) {
 			return value
 		}
 
+		if errObj := ev.allocate(1); errObj != nil {
+			return errObj
+		}
 		err := hash.Add(key, value)
 		if err != nil {
-			return newError(err.Error())
+			return newError("%s", err.Error())
 		}
 	}
 
@@ -336,7 +962,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError("unusable as hash key: %s (%s)", index.Type(), index.Inspect())
 	}
 
 	chain, ok := hashObject.Pairs[key.HashKey()]
@@ -351,15 +977,42 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// maxCallDepth caps how deeply function calls nest on one goroutine, so runaway recursion ends in
+// an error instead of overflowing the goroutine's stack, which would take the whole process down
+const maxCallDepth = 10000
+
+func (ev *evaluation) applyFunction(fn object.Object, args []object.Object) object.Object {
+	if errObj := ev.checkCanceled(); errObj != nil {
+		return errObj
+	}
+
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			if fn.Name != "" {
+				return newError("wrong number of arguments to `%s`. got = %d, want = %d",
+					fn.Name, len(args), len(fn.Parameters))
+			}
+			return newError("wrong number of arguments. got = %d, want = %d",
+				len(args), len(fn.Parameters))
+		}
+		if ev.depth >= maxCallDepth {
+			return newError("maximum call depth exceeded: %d", maxCallDepth)
+		}
+		if ev.profile != nil {
+			defer ev.profile.leave(ev.profile.enter(fn))
+		}
 		extendedEnv := extendedFuncEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		ev.depth++
+		evaluated := ev.eval(fn.Body, extendedEnv)
+		ev.depth--
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		// call the object.BuiltinFunction
+		if fn.FnContext != nil {
+			// the evaluation is the context, so the builtin can continue it
+			return fn.FnContext(ev, args...)
+		}
 		return fn.Fn(args...)
 
 	default:
@@ -367,11 +1020,36 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
+// CallFunction calls a Monkey function or builtin from Go, the way a call expression in Monkey
+// code would. Like Eval, each call is an evaluation of its own.
+func CallFunction(fn object.Object, args ...object.Object) object.Object {
+	return CallFunctionContext(context.Background(), fn, args...)
+}
+
+// CallFunctionContext is CallFunction with the limits and cancellation EvalContext takes from
+// ctx. Given the context a builtin registered with RegisterBuiltinContext is called with, it
+// continues the evaluation calling the builtin instead, so a host function calling back into
+// Monkey code stays within that evaluation's limits.
+func CallFunctionContext(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	return evaluationFrom(ctx).applyFunction(fn, args)
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	default:
+		return false
+	}
+}
+
 func extendedFuncEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if !param.IsBlank() {
+			env.Set(param.Value, args[paramIdx])
+		}
 	}
 
 	return env
@@ -385,19 +1063,110 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// isTruthy is the single definition of truthiness used by conditionals, logical operators, `!`
+// and the `truthy`/`bool` builtins: only NULL and false are falsy. In particular 0, "" and []
+// are truthy.
 func isTruthy(obj object.Object) bool {
 	switch obj {
-	case NULL:
+	case NULL, FALSE:
 		return false
-	case TRUE:
+	default:
 		return true
-	case FALSE:
+	}
+}
+
+// objectsEqual reports whether a and b hold the same value: numbers compare by value across
+// integers and floats and across integers and bigints, arrays, hashes and sets compare their
+// contents, and everything else is only equal to itself
+func objectsEqual(a, b object.Object) bool {
+	return deepEqual(a, b, map[[2]object.Object]bool{})
+}
+
+// deepEqual is objectsEqual, keeping track of the pairs of arrays and hashes being compared.
+// Comparing a pair again means it contains itself in the same places, so it's taken as equal:
+// any difference shows up elsewhere.
+func deepEqual(a, b object.Object, comparing map[[2]object.Object]bool) bool {
+	if isIntegral(a) && isIntegral(b) &&
+		(a.Type() == object.BIGINT_OBJ || b.Type() == object.BIGINT_OBJ) {
+		return toBigInt(a).Cmp(toBigInt(b)) == 0
+	}
+
+	if isNumeric(a) && isNumeric(b) {
+		if a.Type() == object.INTEGER_OBJ && b.Type() == object.INTEGER_OBJ {
+			return a.(*object.Integer).Value == b.(*object.Integer).Value
+		}
+		return toFloat(a) == toFloat(b)
+	}
+
+	if a.Type() != b.Type() {
 		return false
-	default:
+	}
+
+	switch a := a.(type) {
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Boolean:
+		return a.Value == b.(*object.Boolean).Value
+	case *object.Array:
+		if comparing[[2]object.Object{a, b}] {
+			return true
+		}
+		comparing[[2]object.Object{a, b}] = true
+
+		other := b.(*object.Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i, e := range a.Elements {
+			if !deepEqual(e, other.Elements[i], comparing) {
+				return false
+			}
+		}
 		return true
+	case *object.Hash:
+		if comparing[[2]object.Object{a, b}] {
+			return true
+		}
+		comparing[[2]object.Object{a, b}] = true
+
+		other := b.(*object.Hash)
+		if hashLen(a) != hashLen(other) {
+			return false
+		}
+		for hashed, chain := range a.Pairs {
+			for _, pair := range chain {
+				otherPair, ok := other.Pairs[hashed].FindPair(pair.Key)
+				if !ok || !deepEqual(pair.Value, otherPair.Value, comparing) {
+					return false
+				}
+			}
+		}
+		return true
+	case *object.Set:
+		other := b.(*object.Set)
+		if a.Len() != other.Len() {
+			return false
+		}
+		for _, m := range a.Members() {
+			if !other.Has(m) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
 	}
 }
 
+func hashLen(hash *object.Hash) int {
+	length := 0
+	for _, chain := range hash.Pairs {
+		length += len(chain)
+	}
+
+	return length
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
@@ -409,3 +1178,14 @@ func isError(obj object.Object) bool {
 
 	return false
 }
+
+// isErrorOrReturn reports whether obj stops the evaluation of the expression around it: an error,
+// or the value of a return on its way out of the enclosing function. A return can happen in any
+// subexpression, e.g. in `x || return 0` or in a branch of an if used as an operand.
+func isErrorOrReturn(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.RETURN_VALUE_OBJ
+	}
+
+	return false
+}
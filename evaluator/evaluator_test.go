@@ -1,9 +1,16 @@
 package evaluator
 
 import (
+	"context"
+	"github.com/kahvecikaan/monkey-lang/ast"
 	"github.com/kahvecikaan/monkey-lang/lexer"
 	"github.com/kahvecikaan/monkey-lang/object"
 	"github.com/kahvecikaan/monkey-lang/parser"
+	"github.com/kahvecikaan/monkey-lang/token"
+	"io"
+	"math"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -35,6 +42,95 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{`float("2.5")`, 2.5},
+		{`-float("2.5")`, -2.5},
+		{`float("2.5") + 1`, 3.5},
+		{`1 + float("2.5")`, 3.5},
+		{`float("2.5") - float("0.5")`, 2.0},
+		{`float("2.5") * 2`, 5.0},
+		{`float(7) / 2`, 3.5},
+		{`7 / float(2)`, 3.5},
+		{`1.5e3`, 1500.0},
+		{`-2.5e-1 * 4`, -1.0},
+		{`1e308`, 1e308},
+		{`5e-324`, 5e-324},
+		{`5e-324 / 2`, 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFloatComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`float("2.5") < 3`, true},
+		{`3 < float("2.5")`, false},
+		{`float("2.5") > float("2.4")`, true},
+		{`float(1) == 1`, true},
+		{`1 != float(1)`, false},
+		{`float("0.5") == float("0.25")`, false},
+		{`inf() > 1e308`, true},
+		{`-inf() < -1e308`, true},
+		{`inf() == inf()`, true},
+		{`nan() == nan()`, false},
+		{`nan() != nan()`, true},
+		{`let x = nan(); x == x`, false},
+		{`nan() < 1`, false},
+		{`nan() > 1`, false},
+		{`nan() == 1`, false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`float(2)`, "2.0"},
+		{`float("2.5")`, "2.5"},
+		{`float("-0.125")`, "-0.125"},
+		{`float("1e21")`, "1e+21"},
+		{`1e21`, "1e+21"},
+		{`1e3`, "1000.0"},
+		{`inf()`, "inf"},
+		{`-inf()`, "-inf"},
+		{`nan()`, "nan"},
+		{`float("inf")`, "inf"},
+		{`1e308 * 10`, "inf"},
+		{`inf() - inf()`, "nan"},
+		{`[1.5, nan()]`, "[1.5, nan]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %s. got = %q, want = %q",
+				tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestNullLiteral(t *testing.T) {
+	testNullObject(t, testEval("null"))
+	testNullObject(t, testEval("let x = null; x"))
+	testBooleanObject(t, testEval("null == if (false) { 1 }"), true)
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -100,6 +196,20 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
 		{"if (10) {10}", 10},
+		{"if (false) { 10 } else if (true) { 20 } else { 30 }", 20},
+		{"if (false) { 10 } else if (false) { 20 } else { 30 }", 30},
+		{"if (false) { 10 } else if (false) { 20 }", nil},
+		{"if (true) { 10 } else if (true) { 20 }", 10},
+		{"let x = 3; if (x < 2) { 1 } else if (x < 3) { 2 } else if (x < 4) { 3 } else { 4 }", 3},
+		{"let x = 3; if (x < 2) { 1 } elif (x < 3) { 2 } elif (x < 4) { 3 } else { 4 }", 3},
+		{"let x = 5; if (x < 2) { 1 } elif (x < 3) { 2 } else if (x < 4) { 3 } else { 4 }", 4},
+		{"if (false) { 10 } elif (false) { 20 }", nil},
+		// a skipped if binds the NULL singleton, as do branches without a value
+		{"let x = if (false) { 1 }; x", nil},
+		{"let x = if (true) { let y = 1 }; x", nil},
+		{"let x = if (true) { }; x", nil},
+		{"let x = if (false) { 1 } else { }; x", nil},
+		{"let f = fn(n) { if (n > 0) { n } }; f(0)", nil},
 	}
 
 	for _, tt := range tests {
@@ -162,6 +272,64 @@ f(10);`,
 	}
 }
 
+func TestReturnInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(arg) { arg || return "default"; arg + "!" }; [f("a"), f(null)]`, "[a!, default]"},
+		{`let f = fn(ok) { ok && return 1; 2 }; [f(true), f(false)]`, "[1, 2]"},
+		{`let f = fn(x) { let y = x || return; y }; f(false)`, "null"},
+		// an if used as an operand is the closest to a ternary
+		{`let f = fn(n) { 10 + if (n < 0) { return "negative" } else { n } }; [f(-1), f(1)]`, "[negative, 11]"},
+		{`let f = fn() { let v = if (true) { return 1 }; v * 100 }; f()`, "1"},
+		{`let f = fn(xs) { [1, if (len(xs) == 0) { return "empty" } else { 2 }, 3] }; [f([]), f([0])]`, "[empty, [1, 2, 3]]"},
+		{`let f = fn() { [1, return 2, 3] }; f()`, "2"},
+		{`let f = fn() { len(return 3) }; f()`, "3"},
+		{`let f = fn() { [1].push(return 4) }; f()`, "4"},
+		{`let f = fn() { {"a": return 5} }; f()`, "5"},
+		{`let f = fn() { [1, 2][return 6] }; f()`, "6"},
+		{`let f = fn() { -(return 7) }; f()`, "7"},
+		{`let f = fn() { 1 |> (return 8) }; f()`, "8"},
+		{`let f = fn() { with (a = return 9) { a } }; f()`, "9"},
+		{`let f = fn() { "abc"[0:return 10] }; f()`, "10"},
+		// only the function the return is in ends
+		{`let inner = fn() { 1 + (return 2) }; let outer = fn() { inner() * 10 }; outer()`, "20"},
+		{`let f = fn() { do { 1 + (return 2) } + 1 }; f()`, "2"},
+		// the operands before the return are still evaluated, and their errors come first
+		{`let f = fn() { missing + (return 1) }; f()`, "identifier not found: missing"},
+		{`null || return 1; 2`, "1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestGuardStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(x) { guard (x > 0) else { return "non-positive" }; x * 2 }; [f(2), f(-1)]`, "[4, non-positive]"},
+		// the else block's value is returned even without a return
+		{`let f = fn(x) { guard (x) else { "no" }; "yes" }; [f(true), f(null)]`, "[yes, no]"},
+		{`let f = fn(x) { guard (x) else { let t = 1 }; 1 }; f(false)`, "null"},
+		{`let f = fn(x) { guard (x) else { } }; [f(true), f(false)]`, "[null, null]"},
+		{`let f = fn(a, b) { guard (a) else { 1 }; guard (b) else { 2 }; 3 }; [f(false, false), f(true, false), f(true, true)]`, "[1, 2, 3]"},
+		{`let f = fn(x) { guard (x) else { let t = 5; t * 2 }; t }; f(false)`, "10"},
+		{`let f = fn(x) { guard (x) else { let t = 5; t }; t }; f(true)`, "identifier not found: t"},
+		// a guard in a do block returns from the function, like a return
+		{`let f = fn() { let v = do { guard (false) else { 1 }; 2 }; v + 10 }; f()`, "1"},
+		{`let f = fn() { guard (missing) else { 1 }; 2 }; f()`, "identifier not found: missing"},
+		{`let f = fn() { guard (false) else { 1 / 0 }; 2 }; f()`, "division by zero"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -192,7 +360,21 @@ if (10 > 1) {
 		},
 		{
 			`{"name": "Monkey"}[fn(x) { x }];`,
-			"unusable as hash key: FUNCTION"},
+			"unusable as hash key: FUNCTION (fn(x) {\nx\n})"},
+		{
+			`{"name": "Monkey"}[[1, 2]];`,
+			"unusable as hash key: ARRAY ([1, 2])"},
+		{
+			`{[1, 2]: "Monkey"};`,
+			"unusable as hash key: ARRAY ([1, 2])"},
+		{
+			"let add = fn(x, y) { x + y }; add(1);",
+			"wrong number of arguments to `add`. got = 1, want = 2",
+		},
+		{
+			"fn(x) { x }(1, 2);",
+			"wrong number of arguments. got = 2, want = 1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -251,6 +433,35 @@ func TestFunctionObjects(t *testing.T) {
 	}
 }
 
+func TestFunctionNames(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+		expected     string
+	}{
+		{"let add = fn(x, y) { x + y; }; add", "add", "fn add(x, y) {\n(x + y)\n}"},
+		{"fn(x) { x; }", "", "fn(x) {\nx\n}"},
+		{"let add = fn(x, y) { x + y; }; let plus = add; plus", "add", "fn add(x, y) {\n(x + y)\n}"},
+		{"let adder = fn(x) { fn(y) { x + y } }; adder(1)", "", "fn(y) {\n(x + y)\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		fn, ok := evaluated.(*object.Function)
+		if !ok {
+			t.Fatalf("object is not Function. got = %T (%+v)", evaluated, evaluated)
+		}
+
+		if fn.Name != tt.expectedName {
+			t.Errorf("function has wrong name. want = %q, got = %q", tt.expectedName, fn.Name)
+		}
+
+		if fn.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. want = %q, got = %q", tt.expected, fn.Inspect())
+		}
+	}
+}
+
 func TestFunctionApplication(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -336,6 +547,194 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("one", "two")`, "wrong number of arguments. got = 2, want = 1"},
 		{`len([1, 2, 3])`, 3},
 		{`len([])`, 0},
+		{`bool(true)`, true},
+		{`bool(false)`, false},
+		{`bool(1)`, true},
+		{`bool(0)`, true},
+		{`bool("")`, true},
+		{`bool([])`, true},
+		{`bool(if (false) { 1 })`, false},
+		{`bool("true")`, true},
+		{`bool("false")`, false},
+		{`bool()`, "wrong number of arguments. got = 0, want = 1"},
+		{`bool(1, 2)`, "wrong number of arguments. got = 2, want = 1"},
+		{`float(2)`, 2.0},
+		{`float(-7)`, -7.0},
+		{`float(float(1))`, 1.0},
+		{`float("2.5")`, 2.5},
+		{`float("-0.125")`, -0.125},
+		{`float("1e3")`, 1000.0},
+		{`float("abc")`, `could not parse "abc" as float`},
+		{`float(true)`, "argument to `float` not supported, got = BOOLEAN"},
+		{`float()`, "wrong number of arguments. got = 0, want = 1"},
+		{`parseInt("42")`, 42},
+		{`parseInt("  -42 ")`, -42},
+		{`parseInt("+7")`, 7},
+		{`parseInt("ff", 16)`, 255},
+		{`parseInt("FF", 16)`, 255},
+		{`parseInt("-101", 2)`, -5},
+		{`parseInt("z", 36)`, 35},
+		{`parseInt("9223372036854775807")`, 9223372036854775807},
+		{`parseInt("-9223372036854775808")`, -9223372036854775807 - 1},
+		{`parseInt("9223372036854775808")`, `"9223372036854775808" is out of range for an integer`},
+		{`parseInt("")`, `could not parse "" as an integer in base 10`},
+		{`parseInt("12abc")`, `could not parse "12abc" as an integer in base 10`},
+		{`parseInt("1 2")`, `could not parse "1 2" as an integer in base 10`},
+		{`parseInt("- 1")`, `could not parse "- 1" as an integer in base 10`},
+		{`parseInt("--1")`, `could not parse "--1" as an integer in base 10`},
+		{`parseInt("2", 2)`, `could not parse "2" as an integer in base 2`},
+		{`parseInt("0xff", 16)`, `could not parse "0xff" as an integer in base 16`},
+		{`parseInt("1.5")`, `could not parse "1.5" as an integer in base 10`},
+		{`parseInt("1", 1)`, "radix to `parseInt` must be between 2 and 36, got 1"},
+		{`parseInt("1", 37)`, "radix to `parseInt` must be between 2 and 36, got 37"},
+		{`parseInt("1", "2")`, "second argument to `parseInt` must be INTEGER, got STRING"},
+		{`parseInt(1)`, "first argument to `parseInt` must be STRING, got INTEGER"},
+		{`parseInt()`, "wrong number of arguments. got = 0, want = 1 or 2"},
+		{`parseFloat("2.5")`, 2.5},
+		{`parseFloat(" -0.125 ")`, -0.125},
+		{`parseFloat("+1e3")`, 1000.0},
+		{`parseFloat("7")`, 7.0},
+		{`parseFloat("1e-400")`, 0.0},
+		{`parseFloat("1e400")`, `"1e400" is out of range for a float`},
+		{`parseFloat("-1e400")`, `"-1e400" is out of range for a float`},
+		{`parseFloat("")`, `could not parse "" as a float`},
+		{`parseFloat("1.5x")`, `could not parse "1.5x" as a float`},
+		{`parseFloat("- 1")`, `could not parse "- 1" as a float`},
+		{`parseFloat(1.5)`, "argument to `parseFloat` must be STRING, got FLOAT"},
+		{`parseFloat("1", "2")`, "wrong number of arguments. got = 2, want = 1"},
+		{`truthy(true)`, true},
+		{`truthy(false)`, false},
+		{`truthy(if (false) { 1 })`, false},
+		{`truthy(0)`, true},
+		{`truthy("")`, true},
+		{`truthy("false")`, true},
+		{`truthy([])`, true},
+		{`truthy({})`, true},
+		{`truthy(fn() {})`, true},
+		{`truthy()`, "wrong number of arguments. got = 0, want = 1"},
+		{`arity(fn() {})`, 0},
+		{`arity(fn(x, y) { x + y })`, 2},
+		{`let add = fn(a, b, c) { a + b + c }; arity(add)`, 3},
+		{`arity(len)`, -1},
+		{`arity(1)`, "argument to `arity` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`arity()`, "wrong number of arguments. got = 0, want = 1"},
+		{`isCallable(fn(x) { x })`, true},
+		{`let f = fn() {}; isCallable(f)`, true},
+		{`isCallable(len)`, true},
+		{`isCallable(1)`, false},
+		{`isCallable("len")`, false},
+		{`isCallable({})`, false},
+		{`isCallable(1, 2)`, "wrong number of arguments. got = 2, want = 1"},
+		{`let add = fn(x, y) { x + y }; partial(add, 1)(2)`, 3},
+		{`let add = fn(x, y) { x + y }; partial(add)(1, 2)`, 3},
+		{`let add = fn(x, y) { x + y }; partial(add, 1, 2)()`, 3},
+		{`let addThree = fn(x, y, z) { x + y + z }; partial(partial(addThree, 1), 2)(3)`, 6},
+		{`partial(len, [1, 2, 3])()`, 3},
+		{`let add = fn(x, y) { x + y }; isCallable(partial(add, 1))`, true},
+		{`let add = fn(x, y) { x + y }; partial(add, 1)(2, 3)`,
+			"wrong number of arguments to `add`. got = 3, want = 2"},
+		{`let add = fn(x, y) { x + y }; partial(add, 1, true)()`,
+			"type mismatch: INTEGER + BOOLEAN"},
+		{`partial(1, 2)`, "first argument to `partial` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`partial()`, "wrong number of arguments. got = 0, want at least 1"},
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(inc, double)(5)`, 11},
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(double, inc)(5)`, 12},
+		{`let inc = fn(x) { x + 1 }; compose(inc)(1)`, 2},
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(inc, inc, double)(5)`, 12},
+		{`let add = fn(x, y) { x + y }; let double = fn(x) { x * 2 }; compose(double, add)(1, 2)`, 6},
+		{`let inc = fn(x) { x + 1 }; compose(inc, len)("four")`, 5},
+		{`let inc = fn(x) { x + 1 }; compose(inc, inc)(true)`, "type mismatch: BOOLEAN + INTEGER"},
+		{`let inc = fn(x) { x + 1 }; compose(inc, 1)`, "argument 2 to `compose` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`compose()`, "wrong number of arguments. got = 0, want at least 1"},
+		{`assert(true)`, nil},
+		{`assert(1 < 2, "math works")`, nil},
+		{`assert(0)`, nil},
+		{`assert(false)`, "assertion failed"},
+		{`assert(null, "value missing")`, "value missing"},
+		{`assert(1 > 2, 12)`, "12"},
+		{`assert(false, "stops"); 1`, "stops"},
+		{`let f = fn() { assert(false, "in f"); 1 }; f()`, "in f"},
+		{`assert()`, "wrong number of arguments. got = 0, want = 1 or 2"},
+		{`assertEq(1, 1)`, nil},
+		{`assertEq(1, float("1"))`, nil},
+		{`assertEq("a", "a")`, nil},
+		{`assertEq(null, null)`, nil},
+		{`assertEq([1, [2, "x"]], [1, [2, "x"]])`, nil},
+		{`assertEq({"a": [1], 2: true}, {2: true, "a": [1]})`, nil},
+		{`assertEq(set([1, 2]), set([2, 1]))`, nil},
+		{`assertEq(len, len)`, nil},
+		{`assertEq(1, 2)`, "assertion failed: 1 != 2"},
+		{`assertEq(1, "1")`, "assertion failed: 1 != 1"},
+		{`assertEq([1, 2], [1, 3], "lists differ")`, "lists differ: [1, 2] != [1, 3]"},
+		{`assertEq([1, 2], [1, 2, 3])`, "assertion failed: [1, 2] != [1, 2, 3]"},
+		{`assertEq({"a": 1}, {"a": 2})`, "assertion failed: {a: 1} != {a: 2}"},
+		{`assertEq({"a": 1}, {"b": 1})`, "assertion failed: {a: 1} != {b: 1}"},
+		{`assertEq(null, false)`, "assertion failed: null != false"},
+		{`assertEq(1)`, "wrong number of arguments. got = 1, want = 2 or 3"},
+		{`let add = fn(a, b) { a + b }; apply(add, [1, 2])`, 3},
+		{`apply(fn() { 7 }, [])`, 7},
+		{`apply(len, ["four"])`, 4},
+		{`let add = fn(a, b) { a + b }; apply(partial(add, 1), [2])`, 3},
+		{`let args = push([1], 2); apply(fn(a, b) { a * b }, args)`, 2},
+		{`let add = fn(a, b) { a + b }; apply(add, [1])`, "wrong number of arguments to `add`. got = 1, want = 2"},
+		{`let add = fn(a, b) { a + b }; apply(add, [1, true])`, "type mismatch: INTEGER + BOOLEAN"},
+		{`apply(len, [1])`, "argument to `len` not supported, got = INTEGER"},
+		{`apply(1, [])`, "first argument to `apply` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`apply(len, "a")`, "second argument to `apply` must be ARRAY, got STRING"},
+		{`apply(len)`, "wrong number of arguments. got = 1, want = 2"},
+		{`len(times(3, fn(i) { i * 2 }))`, 3},
+		{`times(3, fn(i) { i * 2 })[2]`, 4},
+		{`len(times(0, fn(i) { i }))`, 0},
+		{`first(times(1, fn(i) { i + 10 }))`, 10},
+		{`let sum = fn(arr) { if (len(arr) == 0) { 0 } else { first(arr) + sum(rest(arr)) } }; sum(times(5, fn(i) { i }))`, 10},
+		{`times(3, fn(i) { if (i == 1) { i + true } else { i } })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`times(100000000000, fn(i) { 1 / 0 })`, "division by zero"},
+		{`times(2, fn() { 1 })`, "wrong number of arguments. got = 1, want = 0"},
+		{`times(-1, fn(i) { i })`, "first argument to `times` must not be negative, got -1"},
+		{`times("3", fn(i) { i })`, "first argument to `times` must be INTEGER, got STRING"},
+		{`times(3, 3)`, "second argument to `times` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`times(3)`, "wrong number of arguments. got = 1, want = 2"},
+		{`len(each([1, 2, 3], fn(x) { x }))`, 3},
+		{`let arr = [1, 2]; each(arr, fn(x) { x }) == arr`, true},
+		{`let h = {"a": 1}; each(h, fn(k, v) { v }) == h`, true},
+		{`size(each(set([1, 2]), fn(x) { x }))`, 2},
+		{`len(each([], fn(x) { x + true }))`, 0},
+		{`each([1, 2], fn(x) { if (x == 2) { x + true } })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`each({"a": 1}, fn(k, v) { k + v })`, "type mismatch: STRING + INTEGER"},
+		{`each({"a": 1}, fn(k) { k })`, "wrong number of arguments. got = 2, want = 1"},
+		{`each([1], 1)`, "second argument to `each` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`each("ab", fn(x) { x })`, "first argument to `each` must be ARRAY, HASH or SET, got STRING"},
+		{`each([1])`, "wrong number of arguments. got = 1, want = 2"},
+		{`hashable(1)`, true},
+		{`hashable("a")`, true},
+		{`hashable(true)`, true},
+		{`hashable(null)`, true},
+		{`hashable(float("1.5"))`, false},
+		{`hashable([1])`, false},
+		{`hashable({})`, false},
+		{`hashable(fn(x) { x })`, false},
+		{`hashable(len)`, false},
+		{`hashable(set([]))`, false},
+		{`hashable()`, "wrong number of arguments. got = 0, want = 1"},
+		{`size(set([1, 2, 2, 3, 1]))`, 3},
+		{`size(set([]))`, 0},
+		{`size(set([1, "1", true, null]))`, 4},
+		{`has(set([1, 2]), 2)`, true},
+		{`has(set([1, 2]), 3)`, false},
+		{`has(set(["a"]), "a")`, true},
+		{`has(set([1]), [1])`, false},
+		{`let s = set([1]); let t = add(s, 2); size(s) + size(t)`, 3},
+		{`let s = set([1, 2]); let t = remove(s, 2); has(s, 2) && !has(t, 2)`, true},
+		{`size(remove(set([1]), 5))`, 1},
+		{`size(add(set([1]), 1))`, 1},
+		{`set([fn(x) { x }])`, "unusable as set element: FUNCTION (fn(x) {\nx\n})"},
+		{`add(set([]), [1])`, "unusable as set element: ARRAY ([1])"},
+		{`set(1)`, "argument to `set` must be ARRAY, got INTEGER"},
+		{`add([], 1)`, "first argument to `add` must be SET, got ARRAY"},
+		{`size([1])`, "argument to `size` must be SET, got ARRAY"},
+		{`union(set([1]), [1])`, "second argument to `union` must be SET, got ARRAY"},
+		{`intersect(1, set([1]))`, "first argument to `intersect` must be SET, got INTEGER"},
+		{`difference(set([1]))`, "wrong number of arguments. got = 1, want = 2"},
 	}
 
 	for _, tt := range tests {
@@ -344,6 +743,12 @@ func TestBuiltinFunctions(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
 		case string:
 			errObj, ok := evaluated.(*object.Error)
 			if !ok {
@@ -482,6 +887,28 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashLiteralKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{1: "a", true: "b", "k": "c"}`, "{1: a, k: c, true: b}"},
+		{`let h = {1: "a", true: "b", "k": "c"}; [h[1], h[true], h["k"]]`, "[a, b, c]"},
+		{`{1: "a", "1": "b"}["1"]`, "b"},
+		{`{"a": 1, "a": 2}`, "{a: 2}"},
+		{`{"a": 1, "b": 2, "a": 3}["a"]`, "3"},
+		{`{1: "x", 2 - 1: "y"}`, "{1: y}"},
+		{`{true: 1, 1 < 2: 2}[true]`, "2"},
+		{`{null: 1}[null]`, "1"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY ([1])"},
+		{`{fn(x) { x }: 1}`, "unusable as hash key: FUNCTION (fn(x) {\nx\n})"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -515,6 +942,22 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{null: 5}[null]`,
+			5,
+		},
+		{
+			`{null: 5}[if (false) { 1 }]`,
+			5,
+		},
+		{
+			`{null: 5, false: 6}[false]`,
+			6,
+		},
+		{
+			`{"foo": 5}[null]`,
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -631,6 +1074,16 @@ func TestLogicalOperators(t *testing.T) {
 		{"true && false && true", false},
 		{"false || false || true", true},
 		{"true && (false || true)", true},
+		{"1 && true", true},
+		{"0 && true", true},
+		{`"" || false`, true},
+		{"[] && 1", true},
+		{"if (false) { 1 } || false", false},
+		{"if (false) { 1 } && true", false},
+		{"!0", false},
+		{`!""`, false},
+		{"![]", false},
+		{"!if (false) { 1 }", true},
 	}
 
 	for _, tt := range tests {
@@ -639,6 +1092,133 @@ func TestLogicalOperators(t *testing.T) {
 	}
 }
 
+func TestLogicalOperatorsShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		// the right operand would be an error if it were evaluated
+		{"false && missing", false},
+		{"true || missing", true},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval("true && missing")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got = %T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: missing" {
+		t.Errorf("wrong error message. got = %q", errObj.Message)
+	}
+}
+
+func TestTruthinessInConditionals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"if (0) { 1 } else { 2 }", 1},
+		{`if ("") { 1 } else { 2 }`, 1},
+		{"if ([]) { 1 } else { 2 }", 1},
+		{"if ({}) { 1 } else { 2 }", 1},
+		{"if (false) { 1 } else { 2 }", 2},
+		{"if (if (false) { 1 }) { 1 } else { 2 }", 2},
+		{"if (0 && false) { 1 } else { 2 }", 2},
+		{"if (false || 0) { 1 } else { 2 }", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestPipeOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let double = fn(x) { x * 2 }; 5 |> double", 10},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> double |> inc", 11},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> inc |> double", 12},
+		{"let sub = fn(x, y) { x - y }; 10 |> sub(3)", 7},
+		{"let addAll = fn(x, y, z) { x + y + z }; 1 |> addAll(2, 3)", 6},
+		{"[1, 2, 3] |> len", 3},
+		{"[1, 2] |> push(3) |> len", 3},
+		{"5 |> fn(x) { x * x }", 25},
+		{"2 + 3 |> fn(x) { x * 10 }", 50},
+		{"5 |> 1", "not a function: INTEGER"},
+		{"5 |> missing", "identifier not found: missing"},
+		{"missing |> len", "identifier not found: missing"},
+		{"let sub = fn(x, y) { x - y }; 10 |> sub(missing)", "identifier not found: missing"},
+		{"let sub = fn(x, y) { x - y }; 10 |> sub", "wrong number of arguments to `sub`. got = 1, want = 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected = %q, got = %q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestMethodCallSugar(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3].len()", 3},
+		{`"hello".len()`, 5},
+		{"[1, 2, 3].push(4).len()", 4},
+		{"[1, 2, 3].last()", 3},
+		{"let double = fn(x) { x * 2 }; 21.double()", 42},
+		{"let add = fn(x, y) { x + y }; 1.add(2)", 3},
+		{"let add = fn(x, y) { x + y }; let n = 5; n.add(n)", 10},
+		{"1.missing()", "identifier not found: missing"},
+		{"missing.len()", "identifier not found: missing"},
+		{"let add = fn(x, y) { x + y }; 1.add()", "wrong number of arguments to `add`. got = 1, want = 2"},
+		{"1.len()", "argument to `len` not supported, got = INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected = %q, got = %q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -664,6 +1244,22 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got = %T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got = %g, want = %g",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -687,3 +1283,1486 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 
 	return true
 }
+
+// testInspected checks the message of an error, or the inspected value of anything else
+func testInspected(t *testing.T, input, expected string) {
+	t.Helper()
+
+	evaluated := testEval(input)
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		if errObj.Message != expected {
+			t.Errorf("wrong error for %q. expected = %q, got = %q", input, expected, errObj.Message)
+		}
+		return
+	}
+
+	if evaluated.Inspect() != expected {
+		t.Errorf("wrong result for %q. expected = %q, got = %q", input, expected, evaluated.Inspect())
+	}
+}
+
+func TestOptionalSemicolons(t *testing.T) {
+	input := `
+let add = fn(x, y) {
+  x + y
+}
+let values = [1, 2]
+(add(values[0], values[1]))
+`
+	testIntegerObject(t, testEval(input), 3)
+
+	testIntegerObject(t, testEval("let x = 5\n-3"), -3)
+}
+
+func TestEmptyPrograms(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t\r\n  ",
+		"// just a comment",
+		"// one\n  // two\n",
+	}
+
+	for _, input := range inputs {
+		testNullObject(t, testEval(input))
+	}
+
+	testIntegerObject(t, testEval("// comment\n5 // five\n// done"), 5)
+}
+
+func TestBlockValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = if (true) { 1 } else { 2 }; x", 1},
+		{"let x = if (false) { 1 } else { 2 }; x", 2},
+		{"let f = fn(c) { if (c) { 1 } else { 2 } }; f(true) + f(false)", 3},
+		{"let f = fn(x) { let y = x * 2; y + 1 }; f(2)", 5},
+		{"let f = fn() { 1; 2; 3 }; f()", 3},
+		{"if (true) { 1; 2 }", 2},
+		// blocks without a value yield NULL rather than nothing
+		{"let f = fn() { let a = 1; }; f()", nil},
+		{"let f = fn() { }; f()", nil},
+		{"if (true) { }", nil},
+		{"if (true) { let a = 1 }", nil},
+		{"let x = if (true) { let a = 1 }; x", nil},
+		// an explicit return still leaves the function early from nested blocks
+		{"let f = fn() { if (true) { return 1; }; 2 }; f()", 1},
+		{"let f = fn() { if (true) { if (true) { return 1; }; 2 }; 3 }; f()", 1},
+		{"let f = fn() { if (false) { return 1; }; 2 }; f()", 2},
+		{"let f = fn() { let g = fn() { return 1; }; g() + 1 }; f()", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestIfBranchScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { let t = 5; t * 2 }", 10},
+		{"let a = 1; if (true) { let a = 2; a } + a", 3},
+		{"let a = 1; if (true) { let a = 2; }; a", 1},
+		{"let a = 1; if (false) { 0 } else { let a = 2; }; a", 1},
+		{"let a = 1; if (true) { let b = a + 1; if (true) { let c = b * 10; c + a } }", 21},
+		{"let a = 1; let f = fn() { if (true) { let a = 2; }; a }; f()", 1},
+		{"let f = if (true) { let t = 3; fn() { t } }; f()", 3},
+		{"if (true) { let t = 1 }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+
+	for _, input := range []string{
+		"if (true) { let t = 1; }; t",
+		"if (false) { 1 } else { let t = 1; }; t",
+		"let f = fn() { if (true) { let t = 1; }; t }; f()",
+	} {
+		testInspected(t, input, "identifier not found: t")
+	}
+}
+
+func TestDoExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = do { let t = 5; t * 2 }; x", 10},
+		{"do { 1; 2 }", 2},
+		{"do { }", nil},
+		{"do { let t = 1 }", nil},
+		{"let a = 1; do { let a = 2; a } + a", 3},
+		{"let a = 1; do { let a = 2; }; a", 1},
+		{"let a = 1; let b = do { let c = a + 1; do { c * 10 } }; b", 20},
+		// a return goes through the block, ending the function or program it's in
+		{"let f = fn() { let x = do { return 1; 2 }; x + 10 }; f()", 1},
+		{"let x = do { return 1; 2 }; x + 10", 1},
+		{"let f = fn() { let x = do { if (true) { return 1; }; 3 }; x + 1 }; f()", 1},
+		{"let f = fn() { let x = do { if (false) { return 1; }; 3 }; x + 1 }; f()", 4},
+		{"return do { 7 }; 8", 7},
+		{"let f = fn() { do { fn(y) { y * 2 } } }; f()(4)", 8},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+
+	evaluated := testEval("do { let t = 1; t }; t")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: t" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestWithExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"with (x = 5, y = 10) { x + y }", "15"},
+		{"with (x = 5, y = x * 2) { x + y }", "15"},
+		{"let x = 1; with (x = 2) { x } + x", "3"},
+		{"let x = 1; with (x = x + 1) { x }", "2"},
+		{"let x = 1; with (y = 2) { let x = 5; x + y }; x", "1"},
+		{"with (x = 1) { }", "null"},
+		{"with (_ = 1, y = 2) { y }", "2"},
+		{"with (fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }) { fact(5) }", "120"},
+		{"with (x = 5) { fn(y) { x + y } }(1)", "6"},
+		// a return leaves the enclosing function, not just the block
+		{"let f = fn() { with (a = 1) { return a + 1; 99 }; 100 }; f()", "2"},
+		{"let f = fn(n) { with (m = n) { if (m > 0) { return m; } }; 0 }; [f(3), f(-1)]", "[3, 0]"},
+		{"with (x = 5) { x }; x", "identifier not found: x"},
+		{"with (x = y, y = 1) { y }", "identifier not found: y"},
+		{"with (x = 1 / 0) { x }", "division by zero"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`set([])`, "set([])"},
+		{`set([3, 1, 2, 1])`, "set([1, 2, 3])"},
+		{`set(["b", "a", "b"])`, "set([a, b])"},
+		{`add(set([1]), 2)`, "set([1, 2])"},
+		{`remove(set([1, 2]), 1)`, "set([2])"},
+		{`union(set([1, 2]), set([2, 3]))`, "set([1, 2, 3])"},
+		{`intersect(set([1, 2, 3]), set([2, 3, 4]))`, "set([2, 3])"},
+		{`intersect(set([1]), set([2]))`, "set([])"},
+		{`difference(set([1, 2, 3]), set([2]))`, "set([1, 3])"},
+		{`difference(set([1]), set([]))`, "set([1])"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		set, ok := evaluated.(*object.Set)
+		if !ok {
+			t.Errorf("object is not Set. got = %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if set.Inspect() != tt.expected {
+			t.Errorf("wrong set for %q. expected = %s, got = %s", tt.input, tt.expected, set.Inspect())
+		}
+	}
+}
+
+func TestIntegerBounds(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807", math.MaxInt64},
+		{"-9223372036854775807", -math.MaxInt64},
+		{"-9223372036854775808", math.MinInt64},
+		{"let x = -9223372036854775808; x", math.MinInt64},
+		{"-9223372036854775807 - 1", math.MinInt64},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval("-9223372036854775807 - 1 == -9223372036854775808")
+	testBooleanObject(t, evaluated, true)
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "integer overflow in '9223372036854775807 + 1'"},
+		{"-9223372036854775808 + -1", "integer overflow in '-9223372036854775808 + -1'"},
+		{"9223372036854775807 + -1", "9223372036854775806"},
+		{"-9223372036854775808 + 9223372036854775807", "-1"},
+		{"-9223372036854775808 - 1", "integer overflow in '-9223372036854775808 - 1'"},
+		{"9223372036854775807 - -1", "integer overflow in '9223372036854775807 - -1'"},
+		{"0 - -9223372036854775808", "integer overflow in '0 - -9223372036854775808'"},
+		{"-1 - -9223372036854775808", "9223372036854775807"},
+		{"-9223372036854775808 - -1", "-9223372036854775807"},
+		{"4611686018427387904 * 2", "integer overflow in '4611686018427387904 * 2'"},
+		{"-4611686018427387904 * 2", "-9223372036854775808"},
+		{"-4611686018427387905 * 2", "integer overflow in '-4611686018427387905 * 2'"},
+		{"-9223372036854775808 * -1", "integer overflow in '-9223372036854775808 * -1'"},
+		{"-1 * -9223372036854775808", "integer overflow in '-1 * -9223372036854775808'"},
+		{"-9223372036854775808 * 1", "-9223372036854775808"},
+		{"-9223372036854775808 * 0", "0"},
+		{"3037000499 * 3037000499", "9223372030926249001"},
+		{"3037000500 * 3037000500", "integer overflow in '3037000500 * 3037000500'"},
+		{"-9223372036854775808 / -1", "integer overflow in '-9223372036854775808 / -1'"},
+		{"-9223372036854775808 / 1", "-9223372036854775808"},
+		{"let x = -9223372036854775808; -x", "integer overflow in '-(-9223372036854775808)'"},
+		{"-(-9223372036854775807)", "9223372036854775807"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestDivisionAndModulo(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"7 % -3", "1"},
+		{"6 % 3", "0"},
+		{"-9223372036854775808 % -1", "0"},
+		{"1 + 7 % 4 * 2", "7"},
+		{`float("7.5") % 2`, "1.5"},
+		{`7 % float("2.5")`, "2.0"},
+		{`bigint(7) % 3`, "1"},
+		{`bigint(-7) % 3`, "-1"},
+		{"5 / 0", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"0 / 0", "division by zero"},
+		{`float("5") / 0`, "division by zero"},
+		{`5 / float("0")`, "division by zero"},
+		{`float("5") % float("0")`, "division by zero"},
+		{`5 / float("-0")`, "division by zero"},
+		{`bigint(5) / 0`, "division by zero"},
+		{`5 % bigint(0)`, "division by zero"},
+		{"let f = fn(x) { 10 / x }; f(0)", "division by zero"},
+		{`"a" / 0`, "type mismatch: STRING / INTEGER"},
+		{`"a" % "b"`, "unknown operator: STRING % STRING"},
+		{"true % 2", "type mismatch: BOOLEAN % INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestCallFunction(t *testing.T) {
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New("let add = fn(a, b) { a + b };")).ParseProgram()
+	Eval(program, env)
+
+	add, _ := env.Get("add")
+	testIntegerObject(t, CallFunction(add, object.NewInteger(1), object.NewInteger(2)), 3)
+	testIntegerObject(t, CallFunction(builtins["len"], object.NewString("four")), 4)
+
+	errObj, ok := CallFunction(add, object.NewInteger(1)).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned for wrong arity")
+	}
+	if errObj.Message != "wrong number of arguments to `add`. got = 1, want = 2" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	err := RegisterBuiltin("hostGreeting", func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got = %d, want = 1", len(args))
+		}
+		return object.NewString("hello " + args[0].Inspect())
+	})
+	if err != nil {
+		t.Fatalf("RegisterBuiltin returned error: %s", err)
+	}
+	defer delete(builtins, "hostGreeting")
+
+	evaluated := testEval(`hostGreeting("monkey")`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got = %T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "hello monkey" {
+		t.Errorf("String has wrong value. got = %q", str.Value)
+	}
+
+	if !IsBuiltin("hostGreeting") {
+		t.Errorf("registered builtin is not reported by IsBuiltin")
+	}
+
+	err = RegisterBuiltin("len", func(args ...object.Object) object.Object { return NULL })
+	if err == nil {
+		t.Fatalf("expected error when registering over an existing builtin")
+	}
+	if err.Error() != "builtin already registered: len" {
+		t.Errorf("wrong error message. got = %q", err.Error())
+	}
+	testIntegerObject(t, testEval(`len("four")`), 4)
+}
+
+func TestRegisteredBuiltinCallingBack(t *testing.T) {
+	// a host function calling back into Monkey code on its own, or as part of the evaluation
+	// calling it
+	RegisterBuiltin("hostCall", func(args ...object.Object) object.Object {
+		return CallFunction(args[0], args[1:]...)
+	})
+	defer delete(builtins, "hostCall")
+	RegisterBuiltinContext("hostCallContext", func(ctx context.Context, args ...object.Object) object.Object {
+		return CallFunctionContext(ctx, args[0], args[1:]...)
+	})
+	defer delete(builtins, "hostCallContext")
+
+	testIntegerObject(t, testEval("hostCall(fn(x) { x * 2 }, 21)"), 42)
+	testIntegerObject(t, testEval("hostCallContext(fn(x) { x * 2 }, 21)"), 42)
+	testIntegerObject(t, testEval("hostCall(hostCall, fn() { 1 })"), 1)
+
+	// the callback counts against the limits of the evaluation calling the builtin
+	ctx := WithStepLimit(context.Background(), 100)
+	input := "let loop = fn(n) { if (n > 0) { loop(n - 1) } }; hostCallContext(loop, 1000)"
+	errObj, ok := testEvalContext(ctx, input).(*object.Error)
+	if !ok || errObj.Message != "step limit exceeded: 100" {
+		t.Errorf("callback escaped the step limit. got=%v", testEvalContext(ctx, input))
+	}
+}
+
+func TestBareReturn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn() { return; }; f()", nil},
+		{"let f = fn() { return }; f()", nil},
+		{"let f = fn() { return; 1 }; f()", nil},
+		{"let f = fn(x) { if (x > 0) { return; }; x }; f(1)", nil},
+		{"let f = fn(x) { if (x > 0) { return; }; x }; f(-1)", -1},
+		{"let f = fn() { if (true) { if (true) { return } }; 1 }; f()", nil},
+		{"let f = fn() { return; }; let g = fn() { f(); 2 }; g()", 2},
+		{"return; 1", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestIndexThenCall(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let dispatch = {"add": fn(a, b) { a + b }, "sub": fn(a, b) { a - b }}; dispatch["add"](1, 2)`, 3},
+		{`let dispatch = {"add": fn(a, b) { a + b }, "sub": fn(a, b) { a - b }}; dispatch["sub"](5, 2)`, 3},
+		{`let ops = {"len": len}; ops["len"]([1, 2, 3])`, 3},
+		{`let handlers = [fn(x) { x + 1 }, fn(x) { x * 2 }]; handlers[1](5)`, 10},
+		{`let table = {"math": {"double": fn(x) { x * 2 }}}; table["math"]["double"](4)`, 8},
+		{`let makeOps = fn(n) { {"addN": fn(x) { x + n }} }; makeOps(10)["addN"](1)`, 11},
+		{`let run = fn(op, a, b) { let ops = {"add": fn(a, b) { a + b }}; ops[op](a, b) }; run("add", 2, 3)`, 5},
+		{`let dispatch = {"add": fn(a, b) { a + b }}; dispatch["mul"](1, 2)`, "not a function: NULL"},
+		{`let dispatch = {"one": 1}; dispatch["one"](1)`, "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got = %T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected = %q, got = %q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestImmediatelyInvokedFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn(x) { x * 2 }(21)", 42},
+		{"(fn(x) { x * 2 })(21)", 42},
+		{"fn() { 5 }()", 5},
+		{"fn(a, b) { a + b }(1, 2) * 3", 9},
+		{"let y = 10; fn(x) { x + y }(1)", 11},
+		{"let x = fn(n) { n * n }(4); x", 16},
+		{"let counter = fn(start) { fn() { start + 1 } }(5); counter()", 6},
+		{"fn(x) { fn(y) { x + y } }(1)(2)", 3},
+		{"let f = fn(n) { fn(m) { n * m }(n + 1) }; f(3)", 12},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+// closures hold on to the environment they were created in, not a copy of it
+func TestClosureCapture(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// a binding made after the closure was created is visible to it
+		{"let f = fn() { x }; let x = 2; f()", 2},
+		{"let x = 1; let f = fn() { x }; let x = 2; f()", 2},
+		{"let make = fn() { let f = fn() { n }; let n = 7; f }; make()()", 7},
+		// a let inside a closure binds in the call's own environment and shadows the captured one
+		{"let x = 1; let f = fn() { let x = 5; x }; f() + x", 6},
+		{"let x = 1; let f = fn() { let x = x + 1; x }; f(); f(); x", 1},
+		{"let x = 1; let f = fn() { let x = x + 1; x }; f() + f()", 4},
+		// closures made by the same call share its environment
+		{"let make = fn(n) { [fn() { n }, fn() { n * 2 }] }; let fs = make(3); fs[0]() + fs[1]()", 9},
+		// closures made by separate calls don't
+		{"let make = fn(n) { fn() { n } }; let a = make(1); let b = make(2); a() * 10 + b()", 12},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBuiltinsBuiltin(t *testing.T) {
+	evaluated := testEval("builtins()")
+	arr, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got = %T (%+v)", evaluated, evaluated)
+	}
+
+	if len(arr.Elements) != len(builtins) {
+		t.Fatalf("wrong number of names. got = %d, want = %d", len(arr.Elements), len(builtins))
+	}
+
+	names := map[string]bool{}
+	for i, e := range arr.Elements {
+		name, ok := e.(*object.String)
+		if !ok {
+			t.Fatalf("element %d is not String. got = %T", i, e)
+		}
+		if i > 0 && arr.Elements[i-1].(*object.String).Value >= name.Value {
+			t.Errorf("names not sorted: %q before %q", arr.Elements[i-1].Inspect(), name.Value)
+		}
+		names[name.Value] = true
+	}
+
+	for _, name := range []string{"len", "puts", "partial", "builtins"} {
+		if !names[name] {
+			t.Errorf("builtin %q is not listed", name)
+		}
+	}
+
+	RegisterBuiltin("hostOnly", func(args ...object.Object) object.Object { return NULL })
+	defer delete(builtins, "hostOnly")
+
+	testBooleanObject(t, testEval(`has(set(builtins()), "hostOnly")`), true)
+
+	errObj, ok := testEval("builtins(1)").(*object.Error)
+	if !ok || errObj.Message != "wrong number of arguments. got = 1, want = 0" {
+		t.Errorf("wrong error for builtins(1). got = %+v", errObj)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`help("len")`, "len(x) -> integer: the number of elements of an array or bytes of a string, 0 for null"},
+		{`help(len)`, "len(x) -> integer: the number of elements of an array or bytes of a string, 0 for null"},
+		{`help("help")`, "help(name) -> string: the signature and description of a builtin"},
+		// the builtins made by partial, compose and memoize describe what they wrap
+		{`help(partial(len))`, "partial(len)(args...) -> any: len(args...)"},
+		{`let add = fn(a, b) { a + b }; help(partial(add, 1))`, "partial(add)(args...) -> any: add(1, args...)"},
+		{`help(compose(len, fn(x) { x }))`, "compose(len, fn)(args...) -> any: len(fn(args...))"},
+		{`help(memoize(len))`, "memoize(len)(args...) -> any: len(args...), remembered for hashable arguments"},
+		{`help(partial(memoize(len)))`, "partial(memoize(len))(args...) -> any: memoize(len)(args...)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String for %q. got = %T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("wrong help for %q. expected = %q, got = %q", tt.input, tt.expected, str.Value)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`help("nope")`, "unknown builtin: nope"},
+		{`help(1)`, "argument to `help` must be STRING or BUILTIN, got INTEGER"},
+		{`help()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected = %q, got = %q", tt.expected, errObj.Message)
+		}
+	}
+
+	for name, builtin := range builtins {
+		if builtin.Name != name {
+			t.Errorf("builtin %q has wrong name %q", name, builtin.Name)
+		}
+		if builtin.Doc == "" {
+			t.Errorf("builtin %q has no documentation", name)
+		}
+	}
+
+	RegisterBuiltin("undocumented", func(args ...object.Object) object.Object { return NULL })
+	defer delete(builtins, "undocumented")
+
+	str, ok := testEval(`help("undocumented")`).(*object.String)
+	if !ok || str.Value != "undocumented: no documentation" {
+		t.Errorf("wrong help for an undocumented builtin. got = %+v", str)
+	}
+
+	if doc := BuiltinDoc(&object.Builtin{}); doc != "builtin function: no documentation" {
+		t.Errorf("wrong help for an unnamed builtin. got = %q", doc)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	// a prefix expression without an operand can't come out of the parser
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let", Line: 1, Column: 1},
+			Name:  &ast.Identifier{Value: "a"},
+			Value: &ast.IntegerLiteral{Value: 1},
+		},
+		&ast.ExpressionStatement{
+			Token:      token.Token{Type: token.MINUS, Literal: "-", Line: 2, Column: 3},
+			Expression: &ast.PrefixExpression{Operator: "-"},
+		},
+	}}
+
+	evaluated := Eval(program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got = %T (%+v)", evaluated, evaluated)
+	}
+
+	prefix := "internal error: runtime error: invalid memory address or nil pointer dereference"
+	suffix := "(in - statement at line 2, column 3)"
+	if !strings.HasPrefix(errObj.Message, prefix) || !strings.HasSuffix(errObj.Message, suffix) {
+		t.Errorf("wrong error message. got = %q", errObj.Message)
+	}
+
+	// evaluation works as usual afterwards
+	testIntegerObject(t, testEval("1 + 1"), 2)
+}
+
+func TestFunctionIdentity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let f = fn(x) { x }; f == f", true},
+		{"let f = fn(x) { x }; f != f", false},
+		{"let f = fn(x) { x }; let g = f; f == g", true},
+		{"let f = fn(x) { x }; let g = fn(x) { x }; f == g", false},
+		{"let f = fn(x) { x }; let g = fn(x) { x }; f != g", true},
+		{"let make = fn() { fn() { 1 } }; make() == make()", false},
+		{"len == len", true},
+		{"len == first", false},
+		{"len != puts", true},
+		{"let f = fn(x) { x }; f == len", false},
+		{"let f = fn(x) { x }; f == 1", false},
+		{"let f = fn(x) { x }; [f][0] == f", true},
+		{"let f = fn(x) { x }; {\"f\": f}[\"f\"] == f", true},
+		{"let f = fn(x) { x }; partial(f, 1) == partial(f, 1)", false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	evaluated := testEval("let f = fn(x) { x }; f < f")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got = %T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "unknown operator: FUNCTION < FUNCTION" {
+		t.Errorf("wrong error message. got = %q", errObj.Message)
+	}
+}
+
+func TestEnums(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"enum Color { Red, Green }; Color.Red == Color.Red", true},
+		{"enum Color { Red, Green }; Color.Red == Color.Green", false},
+		{"enum Color { Red, Green }; Color.Red != Color.Green", true},
+		{"enum Color { Red, Green }; let c = Color.Green; c == Color.Green", true},
+		{"enum A { X }; enum B { X }; A.X == B.X", false},
+		{"enum Color { Red, Green }; let names = {Color.Red: \"red\", Color.Green: \"green\"}; len(names[Color.Green])", 5},
+		{"enum Color { Red, Green }; has(set([Color.Red, Color.Red]), Color.Red)", true},
+		{"enum Color { Red, Green }; size(set([Color.Red, Color.Green, Color.Red]))", 2},
+		{"enum Color { Red, Green }; let isRed = fn(c) { c == Color.Red }; isRed(Color.Red)", true},
+		{"let f = fn() { enum Dir { Up, Down }; Dir.Down }; f() == f()", false},
+		{"enum Color { Red }; Color.Blue", "unknown member of enum Color: Blue"},
+		{"let h = {}; h.key", "member access not supported: HASH"},
+		{"Color.Red", "identifier not found: Color"},
+		{"enum Color { Red }; Color.Red + 1", "type mismatch: ENUM_VALUE + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got = %T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected = %q, got = %q", expected, errObj.Message)
+			}
+		}
+	}
+
+	inspectTests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Color { Red, Green }; Color", "enum Color { Red, Green }"},
+		{"enum Color { Red, Green }; Color.Green", "Color.Green"},
+		{"enum Color { Red, Green }; [Color.Red, Color.Green]", "[Color.Red, Color.Green]"},
+	}
+
+	for _, tt := range inspectTests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong inspect for %q. expected = %q, got = %q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEachVisitsInOrder(t *testing.T) {
+	var visited []string
+	RegisterBuiltin("record", func(args ...object.Object) object.Object {
+		parts := []string{}
+		for _, arg := range args {
+			parts = append(parts, arg.Inspect())
+		}
+		visited = append(visited, strings.Join(parts, "="))
+		return NULL
+	})
+	defer delete(builtins, "record")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`each([3, 1, 2], record)`, []string{"3", "1", "2"}},
+		{`each({"b": 2, "a": 1}, record)`, []string{"a=1", "b=2"}},
+		{`each(set([2, 1]), record)`, []string{"1", "2"}},
+		{`each([1, 2, 3], fn(x) { if (x == 2) { x + true } else { record(x) } })`, []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		visited = nil
+		testEval(tt.input)
+
+		if strings.Join(visited, ", ") != strings.Join(tt.expected, ", ") {
+			t.Errorf("wrong calls for %q. expected = %q, got = %q", tt.input, tt.expected, visited)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3, 4][:2]`, "[1, 2]"},
+		{`[1, 2, 3, 4][2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][::2]`, "[1, 3]"},
+		{`[1, 2, 3, 4][-2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:-1]`, "[1, 2, 3]"},
+		{`[1, 2, 3, 4][::-1]`, "[4, 3, 2, 1]"},
+		{`[1, 2, 3, 4][::-2]`, "[4, 2]"},
+		{`[1, 2, 3, 4][2::-1]`, "[3, 2, 1]"},
+		{`[1, 2, 3, 4][3:0:-1]`, "[4, 3, 2]"},
+		{`[1, 2, 3, 4][-1:-3:-1]`, "[4, 3]"},
+		{`[1, 2, 3, 4][10:]`, "[]"},
+		{`[1, 2, 3, 4][-10:2]`, "[1, 2]"},
+		{`[1, 2, 3, 4][3:1]`, "[]"},
+		{`[1, 2, 3, 4][10::-1]`, "[4, 3, 2, 1]"},
+		{`[][::-1]`, "[]"},
+		{`"hello"[1:4]`, "ell"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"héllo"[0:3]`, "hé"},
+		{`"héllo"[::-1]`, "slice step must be 1 for a string with non-ASCII characters, got -1"},
+		{`"héllo"[::2]`, "slice step must be 1 for a string with non-ASCII characters, got 2"},
+		{`[1, 2, 3][::0]`, "slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "slice bounds must be INTEGER, got STRING"},
+		{`{"a": 1}[0:1]`, "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`flatten([[1, 2], [3], 4])`, "[1, 2, 3, 4]"},
+		{`flatten([1, [2, [3, [4]]]])`, "[1, 2, [3, [4]]]"},
+		{`flatten([1, [2, [3, [4]]]], 2)`, "[1, 2, 3, [4]]"},
+		{`flatten([1, [2, [3, [4]]]], 10)`, "[1, 2, 3, 4]"},
+		{`flatten([1, [2]], 0)`, "[1, [2]]"},
+		{`flatten([[], [[]], "ab", {"a": 1}])`, "[[], ab, {a: 1}]"},
+		{`let a = [1, 2]; flatten([a, a, a])`, "[1, 2, 1, 2, 1, 2]"},
+		{`flatten("ab")`, "first argument to `flatten` must be ARRAY, got STRING"},
+		{`flatten([1], "a")`, "second argument to `flatten` must be INTEGER, got STRING"},
+		{`flatten([1], -1)`, "second argument to `flatten` must not be negative, got -1"},
+		{`flatten()`, "wrong number of arguments. got = 0, want = 1 or 2"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestGroupByAndPartition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`groupBy([1, 2, 3, 4, 5], fn(x) { x - x / 2 * 2 })`, "{0: [2, 4], 1: [1, 3, 5]}"},
+		{`groupBy(["a", "bb", "c"], len)`, "{1: [a, c], 2: [bb]}"},
+		{`groupBy([], len)`, "{}"},
+		{`groupBy([1, 2], fn(x) { [x] })`, "unusable as hash key: ARRAY ([1])"},
+		{`groupBy([1, 2], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`groupBy(1, len)`, "first argument to `groupBy` must be ARRAY, got INTEGER"},
+		{`groupBy([1], 1)`, "second argument to `groupBy` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`groupBy([1])`, "wrong number of arguments. got = 1, want = 2"},
+		{`partition([1, 2, 3, 4, 5], fn(x) { x > 2 })`, "[[3, 4, 5], [1, 2]]"},
+		{`partition([1, null, 0, false], fn(x) { x })`, "[[1, 0], [null, false]]"},
+		{`partition([], fn(x) { x })`, "[[], []]"},
+		{`partition([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`partition("ab", fn(x) { x })`, "first argument to `partition` must be ARRAY, got STRING"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestCountAndFrequencies(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`count([1, 2, 3, 4, 5], fn(x) { x > 2 })`, "3"},
+		{`count([1, null, 0, false], fn(x) { x })`, "2"},
+		{`count([], fn(x) { x })`, "0"},
+		{`count([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`count("ab", fn(x) { x })`, "first argument to `count` must be ARRAY, got STRING"},
+		{`count([1], 1)`, "second argument to `count` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`frequencies([1, 2, 1, "a", 1, "a"])`, "{1: 3, 2: 1, a: 2}"},
+		{`frequencies([1, "1", true])`, "{1: 1, 1: 1, true: 1}"},
+		{`frequencies([])`, "{}"},
+		{`frequencies([[1]])`, "unusable as hash key: ARRAY ([1])"},
+		{`frequencies("ab")`, "argument to `frequencies` must be ARRAY, got STRING"},
+		{`frequencies()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`unique([3, 1, 3, 2, 1])`, "[3, 1, 2]"},
+		{`unique(["b", "a", "b"])`, "[b, a]"},
+		{`unique([1, "1", true, null, null])`, "[1, 1, true, null]"},
+		{`unique([])`, "[]"},
+		{`unique([[1, 2], [1, 2], [2]])`, "[[1, 2], [2]]"},
+		{`unique([{"a": 1}, {"a": 1}])`, "[{a: 1}]"},
+		{`unique([1, float("1"), float("2"), 2])`, "[1, 2.0]"},
+		{`unique([float("1"), 1])`, "[1.0]"},
+		{`let f = fn(x) { x }; len(unique([f, f, fn(x) { x }]))`, "2"},
+		{`unique("ab")`, "argument to `unique` must be ARRAY, got STRING"},
+		{`unique()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestPrettyPrint(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	evaluated := testEval(`pp({"a": [1, [2]]})`)
+	os.Stdout = stdout
+	w.Close()
+
+	printed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testNullObject(t, evaluated)
+
+	expected := "{\n  a: [\n    1,\n    [2]\n  ]\n}\n"
+	if string(printed) != expected {
+		t.Errorf("wrong output. expected = %q, got = %q", expected, printed)
+	}
+
+	testInspected(t, `pp()`, "wrong number of arguments. got = 0, want = 1")
+}
+
+func TestStrHook(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`str(1)`, "1"},
+		{`str("a")`, "a"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`str({"a": 1})`, "{a: 1}"},
+		{`str({"x": 1, "__str__": fn(self) { "point " + str(self["x"]) }})`, "point 1"},
+		{`let p = {"__str__": fn(self) { "p" }}; str([p])`, "[p]"},
+		{`let p = {"__str__": fn(self) { "p" + str(self["x"]) }, "x": 1}; str({"a": [p], "b": {"c": p}})`,
+			"{a: [p1], b: {c: p1}}"},
+		{`let p = {"__str__": fn(self) { 1 }}; str([0, p])`, "`__str__` must return STRING, got INTEGER"},
+		{`let a = [1]; str(push(a, {"__str__": fn(self) { str(a) }}))`, "[1, [1]]"},
+		{`str({"__str__": "not callable"})`, "{__str__: not callable}"},
+		{`str({"__str__": fn(self) { 1 }})`, "`__str__` must return STRING, got INTEGER"},
+		{`str({"__str__": fn() { "a" }})`, "wrong number of arguments. got = 1, want = 0"},
+		{`str({"__str__": fn(self) { 1 + true }})`, "type mismatch: INTEGER + BOOLEAN"},
+		{`puts({"__str__": fn(self) { 1 }})`, "`__str__` must return STRING, got INTEGER"},
+		{`str()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	calls := 0
+	RegisterBuiltin("countCall", func(args ...object.Object) object.Object {
+		calls++
+		return args[0]
+	})
+	defer delete(builtins, "countCall")
+
+	tests := []struct {
+		input    string
+		expected string
+		calls    int
+	}{
+		{`let f = memoize(countCall); [f(1), f(1), f(2), f(1)]`, "[1, 1, 2, 1]", 2},
+		{`let f = memoize(fn(a, b) { countCall(a + b) }); [f(1, 2), f(2, 1), f(1, 2)]`, "[3, 3, 3]", 2},
+		{`let f = memoize(countCall); [f(1), f("1"), f(true), f(null), f(1)]`, "[1, 1, true, null, 1]", 4},
+		{`let f = memoize(fn() { countCall(0) }); [f(), f()]`, "[0, 0]", 1},
+		{`let f = memoize(countCall); [f(1), f(1, 2), f(1, 2)]`, "[1, 1, 1]", 2},
+		{`let f = memoize(countCall); [len(f([1, 2])), len(f([1, 2]))]`, "[2, 2]", 2},
+		{`let f = memoize(fn(x) { countCall(x); x + true }); f(1)`, "type mismatch: INTEGER + BOOLEAN", 1},
+		{`let fib = memoize(fn(n) { if (n < 2) { countCall(n) } else { fib(n - 1) + fib(n - 2) } }); fib(80)`,
+			"23416728348467685", 2},
+		{`memoize(1)`, "argument to `memoize` must be FUNCTION or BUILTIN, got INTEGER", 0},
+		{`memoize()`, "wrong number of arguments. got = 0, want = 1", 0},
+	}
+
+	for _, tt := range tests {
+		calls = 0
+		testInspected(t, tt.input, tt.expected)
+
+		if calls != tt.calls {
+			t.Errorf("wrong number of calls for %q. expected = %d, got = %d", tt.input, tt.calls, calls)
+		}
+	}
+}
+
+func TestBigInts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`bigint(9223372036854775807) + 1`, "9223372036854775808"},
+		{`1 + bigint(9223372036854775807)`, "9223372036854775808"},
+		{`bigint("-9223372036854775808") - 1`, "-9223372036854775809"},
+		{`bigint(4611686018427387904) * 4`, "18446744073709551616"},
+		{`bigint("100000000000000000000") / 3`, "33333333333333333333"},
+		{`bigint(-7) / 2`, "-3"},
+		{`bigint(1) / 0`, "division by zero"},
+		{`-bigint("12345678901234567890")`, "-12345678901234567890"},
+		{`bigint(5) - 5`, "0"},
+		{`bigint("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{`let fact = fn(n) { if (n < 2) { bigint(1) } else { n * fact(n - 1) } }; fact(25)`,
+			"15511210043330985984000000"},
+		{`bigint(2) < 3`, "true"},
+		{`3 > bigint(2)`, "true"},
+		{`bigint(2) == 2`, "true"},
+		{`bigint(2) != bigint(2)`, "false"},
+		{`bigint(2) == float("2")`, "false"},
+		{`bigint(2) + float("2")`, "type mismatch: BIGINT + FLOAT"},
+		{`float(bigint(2))`, "2.0"},
+		{`assertEq(bigint(2), 2)`, "null"},
+		{`{bigint(2): "a"}[bigint(2)]`, "a"},
+		// a bigint is the same key as the integer equal to it
+		{`{1: "a"}[bigint(1)]`, "a"},
+		{`{bigint(1): "a"}[1]`, "a"},
+		{`{1: "a", bigint(1): "b"}`, "{1: b}"},
+		{`size(set([1, bigint(1)]))`, "1"},
+		{`len(unique([1, bigint(1)]))`, "1"},
+		{`has(set([bigint("99999999999999999999")]), bigint("99999999999999999999"))`, "true"},
+		{`bigint("12a")`, "could not parse \"12a\" as bigint"},
+		{`bigint(true)`, "argument to `bigint` not supported, got = BOOLEAN"},
+		{`bigint()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestEqualityOfCyclicValues(t *testing.T) {
+	cyclic := func(last object.Object) *object.Array {
+		arr := &object.Array{Elements: []object.Object{object.NewInteger(1)}}
+		arr.Elements = append(arr.Elements, arr, last)
+		return arr
+	}
+
+	a, b, c := cyclic(object.NewInteger(2)), cyclic(object.NewInteger(2)), cyclic(object.NewInteger(3))
+
+	if !objectsEqual(a, b) {
+		t.Errorf("equal cyclic arrays compared unequal")
+	}
+	if objectsEqual(a, c) {
+		t.Errorf("different cyclic arrays compared equal")
+	}
+
+	h := object.NewHash()
+	h.Add(object.NewString("self"), h)
+	g := object.NewHash()
+	g.Add(object.NewString("self"), g)
+
+	if !objectsEqual(h, g) {
+		t.Errorf("equal cyclic hashes compared unequal")
+	}
+}
+
+func TestSumAndProduct(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sum([1, 2, 3])`, "6"},
+		{`sum([])`, "0"},
+		{`sum([-4])`, "-4"},
+		{`sum([1, 2.5])`, "3.5"},
+		{`sum([0.5, 0.5])`, "1.0"},
+		{`sum([1, bigint("9223372036854775807")])`, "9223372036854775808"},
+		{`sum([9223372036854775807, 1])`, "integer overflow in '9223372036854775807 + 1'"},
+		{`product([2, 3, 4])`, "24"},
+		{`product([])`, "1"},
+		{`product([2, 0.5])`, "1.0"},
+		{`product([4611686018427387904, 2])`, "integer overflow in '4611686018427387904 * 2'"},
+		{`product([bigint(4611686018427387904), 2])`, "9223372036854775808"},
+		{`sum([1, "2"])`, "element 1 of the argument to `sum` must be a number, got STRING"},
+		{`product([[1], 2])`, "element 0 of the argument to `product` must be a number, got ARRAY"},
+		{`sum(1)`, "argument to `sum` must be ARRAY, got INTEGER"},
+		{`product()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestArrayComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2] < [1, 3]`, "true"},
+		{`[1, 3] < [1, 2]`, "false"},
+		{`[1, 3] > [1, 2]`, "true"},
+		{`[2] > [1, 9, 9]`, "true"},
+		{`[1, 2] < [1, 2, 0]`, "true"},
+		{`[1, 2, 0] > [1, 2]`, "true"},
+		{`[] < [1]`, "true"},
+		{`[] < []`, "false"},
+		{`[1, 2] < [1, 2]`, "false"},
+		{`[1, 2] > [1, 2]`, "false"},
+		{`[1, 2.5] < [1, 3]`, "true"},
+		{`[1.0] < [1]`, "false"},
+		{`[bigint(5)] > [4]`, "true"},
+		{`[[1, 2], 0] < [[1, 3], 0]`, "true"},
+		{`[[1], 5] < [[1, 0], 0]`, "true"},
+		{`[true, 1] < [true, 2]`, "true"},
+		{`[nan()] < [nan()]`, "false"},
+		{`[nan()] > [nan()]`, "false"},
+		{`[1, "a"] < [2, 1]`, "type mismatch: STRING < INTEGER"},
+		{`[2, [1, "a"]] > [1, [1, 2]]`, "type mismatch: STRING > INTEGER"},
+		{`[1, len] < [2, len]`, "true"},
+		{`[1, "a"] < [2]`, "true"},
+		{`[1, "a"] < [1, 2]`, "type mismatch: STRING < INTEGER"},
+		{`[true] < [false]`, "unknown operator: BOOLEAN < BOOLEAN"},
+		{`[[1]] < [1]`, "type mismatch: ARRAY < INTEGER"},
+		{`[1] < 2`, "type mismatch: ARRAY < INTEGER"},
+		{`{} < {}`, "unknown operator: HASH < HASH"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`swap([1, 2, 3], 0, 2)`, "[3, 2, 1]"},
+		{`swap([1, 2, 3], 1, 1)`, "[1, 2, 3]"},
+		{`swap(["a", [1]], 1, 0)`, "[[1], a]"},
+		// the array itself changes, so every name bound to it sees the swap
+		{`let a = [1, 2]; let b = a; swap(a, 0, 1); b`, "[2, 1]"},
+		{`let a = [1, 2]; swap(a, 0, 1) == a`, "true"},
+		{`swap([1, 2], 0, 2)`, "index 2 out of range for `swap` on an array of length 2"},
+		{`swap([1, 2], -1, 0)`, "index -1 out of range for `swap` on an array of length 2"},
+		{`swap([], 0, 0)`, "index 0 out of range for `swap` on an array of length 0"},
+		{`swap([1, 2], 0, "1")`, "indices to `swap` must be INTEGER, got STRING"},
+		{`swap({}, 0, 1)`, "first argument to `swap` must be ARRAY, got HASH"},
+		{`swap([1, 2], 0)`, "wrong number of arguments. got = 2, want = 3"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fill(3, 0)`, "[0, 0, 0]"},
+		{`fill(0, 0)`, "[]"},
+		{`fill(2, "ab")`, "[ab, ab]"},
+		{`fill(2, null)`, "[null, null]"},
+		{`fill(4, fn(i) { i * i })`, "[0, 1, 4, 9]"},
+		{`fill(2, len)`, "argument to `len` not supported, got = INTEGER"},
+		// the value is shared, a function makes a new one for each element
+		{`let rows = fill(2, [1, 2]); swap(rows[0], 0, 1); rows`, "[[2, 1], [2, 1]]"},
+		{`let rows = fill(2, fn(i) { [1, 2] }); swap(rows[0], 0, 1); rows`, "[[2, 1], [1, 2]]"},
+		{`fill(-1, 0)`, "first argument to `fill` must not be negative, got -1"},
+		{`fill("3", 0)`, "first argument to `fill` must be INTEGER, got STRING"},
+		{`fill(3)`, "wrong number of arguments. got = 1, want = 2"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`concat([1, 2], [3], [], [[4]])`, "[1, 2, 3, [4]]"},
+		{`concat([1])`, "[1]"},
+		{`concat([], [])`, "[]"},
+		{`concat("ab", "", "c", "de")`, "abcde"},
+		{`concat("")`, ""},
+		// the result is a new array, the arguments are left alone
+		{`let a = [1, 2]; let b = concat(a); swap(b, 0, 1); a`, "[1, 2]"},
+		{`concat([1], "a")`, "argument 2 to `concat` must be ARRAY like the first, got STRING"},
+		{`concat("a", "b", [1])`, "argument 3 to `concat` must be STRING like the first, got ARRAY"},
+		{`concat(1, 2)`, "arguments to `concat` must be ARRAY or STRING, got INTEGER"},
+		{`concat()`, "wrong number of arguments. got = 0, want at least 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestBlankIdentifier(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let second = fn(_, x) { x }; second(1, 2)`, "2"},
+		{`let f = fn(_, _, z) { z }; f(1, 2, 3)`, "3"},
+		{`let _ = 1; let _ = 2; 3`, "3"},
+		{`let f = fn(_) { 1 }; f()`, "wrong number of arguments to `f`. got = 0, want = 1"},
+		// the value is still evaluated, along with any error it has
+		{`let _ = 1 / 0; 2`, "division by zero"},
+		{`let _ = fn(x) { x }; 1`, "1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New(`let _ = 1; let f = fn(_) { 2 }; f(3)`)).ParseProgram(), env)
+	if bindings := env.Bindings(); len(bindings) != 1 || bindings[0].Name != "f" {
+		t.Errorf("_ should not be bound. got = %v", bindings)
+	}
+}
+
+func TestGetIn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`getIn({"a": {"b": [10, 20]}}, ["a", "b", 1])`, "20"},
+		{`getIn([[1, 2], [3, 4]], [1, 0])`, "3"},
+		{`getIn({1: {true: "x"}}, [1, true])`, "x"},
+		{`getIn(5, [])`, "5"},
+		{`getIn({"a": 1}, ["b"])`, "null"},
+		{`getIn({"a": 1}, ["b"], 0)`, "0"},
+		{`getIn([1], [1], "none")`, "none"},
+		{`getIn([1], [-1], "none")`, "none"},
+		{`getIn({"a": null}, ["a", "b"], "none")`, "none"},
+		// a key that's there with a null value isn't missing
+		{`getIn({"a": null}, ["a"], "none")`, "null"},
+		{`getIn({"a": 1}, ["a", "b"])`, "path[1] of `getIn` can't index INTEGER, only ARRAY or HASH"},
+		{`getIn([1], ["a"])`, "path[0] of `getIn` must be INTEGER to index an ARRAY, got STRING"},
+		{`getIn({}, [[1]])`, "unusable as hash key: ARRAY ([1])"},
+		{`getIn({}, "a")`, "second argument to `getIn` must be ARRAY, got STRING"},
+		{`getIn({})`, "wrong number of arguments. got = 1, want = 2 or 3"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestSetIn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`setIn({"a": {"b": 1}}, ["a", "b"], 2)`, "{a: {b: 2}}"},
+		{`setIn({"a": [1, 2]}, ["a", 0], 9)`, "{a: [9, 2]}"},
+		{`setIn([[1], [2]], [1, 0], 5)`, "[[1], [5]]"},
+		{`setIn({}, ["a", "b", "c"], 1)`, "{a: {b: {c: 1}}}"},
+		{`setIn({"a": null}, ["a", "b"], 1)`, "{a: {b: 1}}"},
+		{`setIn(null, ["a"], 1)`, "{a: 1}"},
+		{`setIn(1, [], 2)`, "2"},
+		// the original is left alone, and so are the parts the path doesn't go through
+		{`let d = {"a": {"b": 1}, "c": [1]}; setIn(d, ["a", "b"], 2); d`, "{a: {b: 1}, c: [1]}"},
+		{`let d = {"c": [1]}; setIn(d, ["e"], 2)["c"] == d["c"]`, "true"},
+		{`let d = [[1, 2]]; let e = setIn(d, [0, 1], 3); [d, e]`, "[[[1, 2]], [[1, 3]]]"},
+		{`setIn([1, 2], [2], 3)`, "path[0] of `setIn` is out of range for an array of length 2, got 2"},
+		{`setIn({"a": "s"}, ["a", 0], 1)`, "path[1] of `setIn` can't index STRING, only ARRAY or HASH"},
+		{`setIn([1], ["0"], 1)`, "path[0] of `setIn` must be INTEGER to index an ARRAY, got STRING"},
+		{`setIn({}, [fn() {}], 1)`, "unusable as hash key: FUNCTION (fn() {\n\n})"},
+		{`setIn({}, 1, 1)`, "second argument to `setIn` must be ARRAY, got INTEGER"},
+		{`setIn({}, [])`, "wrong number of arguments. got = 2, want = 3"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"1 + true", 1, 3},
+		{"let a = 1;\nlet b = a * missing;", 2, 13},
+		{"let f = fn(x) {\n  x + true\n};\nf(1)", 2, 5},
+		{"let f = fn(x) {\n  x\n};\n\n  f(1, 2)", 5, 3},
+		{"len(1)", 1, 1},
+		{"[1].first(2)", 1, 5},
+		{"-true", 1, 1},
+		{"{[1]: 2}", 1, 1},
+		{"if (5 / 0) { 1 }", 1, 7},
+		{"1;\n  fn() { yield 1 }()", 2, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got = %T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Line != tt.line || errObj.Column != tt.column {
+			t.Errorf("wrong position for %q. expected = %d:%d, got = %d:%d (%s)",
+				tt.input, tt.line, tt.column, errObj.Line, errObj.Column, errObj.Message)
+		}
+	}
+
+	evaluated := testEval("let x = 1;\nx + \"a\"")
+	expected := `at line 2, col 3: type mismatch: INTEGER + STRING`
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Error() != expected {
+		t.Errorf("wrong error. expected = %q, got = %q", expected, evaluated.Inspect())
+	}
+	if evaluated.Inspect() != "ERROR: "+expected {
+		t.Errorf("wrong Inspect. got = %q", evaluated.Inspect())
+	}
+
+	// errors made outside of Eval have no position
+	errObj := &object.Error{Message: "boom"}
+	if errObj.Error() != "boom" {
+		t.Errorf("an error without a position should only have its message. got = %q", errObj.Error())
+	}
+}
+
+func TestClampAndSign(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`clamp(5, 0, 10)`, "5"},
+		{`clamp(-5, 0, 10)`, "0"},
+		{`clamp(15, 0, 10)`, "10"},
+		{`clamp(0, 0, 10)`, "0"},
+		{`clamp(10, 0, 10)`, "10"},
+		{`clamp(3, 3, 3)`, "3"},
+		{`clamp(1.5, 0, 1)`, "1"},
+		{`clamp(2, 0.5, 1.5)`, "1.5"},
+		{`clamp(-inf(), -1.0, 1.0)`, "-1.0"},
+		{`clamp(bigint("99999999999999999999"), 0, 100)`, "100"},
+		{`clamp(1, 10, 0)`, "lower bound of `clamp` must not be above the upper one, got 10 > 0"},
+		{`clamp("a", 0, 1)`, "arguments to `clamp` must be numbers, got STRING"},
+		{`clamp(1, 0, null)`, "arguments to `clamp` must be numbers, got NULL"},
+		{`clamp(1, 0)`, "wrong number of arguments. got = 2, want = 3"},
+		{`sign(-7)`, "-1"},
+		{`sign(0)`, "0"},
+		{`sign(42)`, "1"},
+		{`sign(-0.5)`, "-1"},
+		{`sign(0.0)`, "0"},
+		{`sign(inf())`, "1"},
+		{`sign(bigint("-99999999999999999999"))`, "-1"},
+		{`sign(nan())`, "argument to `sign` is nan, which has no sign"},
+		{`sign(true)`, "argument to `sign` must be a number, got BOOLEAN"},
+		{`sign()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestEncodingBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`base64Encode("hello")`, "aGVsbG8="},
+		{`base64Encode("")`, ""},
+		{`base64Encode("a+b/c?")`, "YStiL2M/"},
+		{`base64Decode("aGVsbG8=")`, "hello"},
+		{`base64Decode("")`, ""},
+		{`base64Decode(base64Encode("round trip"))`, "round trip"},
+		{`base64Decode("aGVsbG8")`, `could not decode "aGVsbG8" as base64: illegal base64 data at input byte 4`},
+		{`base64Decode("!!!!")`, `could not decode "!!!!" as base64: illegal base64 data at input byte 0`},
+		{`sha256("")`, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{`sha256("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`md5("")`, "d41d8cd98f00b204e9800998ecf8427e"},
+		{`md5("abc")`, "900150983cd24fb0d6963f7d28e17f72"},
+		{`sha256(1)`, "argument to `sha256` must be STRING, got INTEGER"},
+		{`md5([])`, "argument to `md5` must be STRING, got ARRAY"},
+		{`base64Encode()`, "wrong number of arguments. got = 0, want = 1"},
+		{`base64Decode("a", "b")`, "wrong number of arguments. got = 2, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestNullAsEmptyCollection(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len(null)`, "0"},
+		{`first(null)`, "null"},
+		{`last(null)`, "null"},
+		{`rest(null)`, "null"},
+		{`push(null, 1)`, "[1]"},
+		{`flatten(null)`, "[]"},
+		{`unique(null)`, "[]"},
+		{`frequencies(null)`, "{}"},
+		{`sum(null)`, "0"},
+		{`product(null)`, "1"},
+		{`count(null, fn(x) { true })`, "0"},
+		{`partition(null, fn(x) { true })`, "[[], []]"},
+		{`groupBy(null, fn(x) { x })`, "{}"},
+		{`each(null, fn(x) { x })`, "null"},
+		{`size(null)`, "0"},
+		{`set(null)`, "set([])"},
+		{`has(null, 1)`, "false"},
+		{`concat(null, [1])`, "[1]"},
+		{`concat([1], null, [2])`, "[1, 2]"},
+		{`apply(fn() { 1 }, null)`, "1"},
+		{`toArray(null)`, "[]"},
+		{`let config = {"tags": ["a", "b"]}; len(config["names"])`, "0"},
+		{`{}["tags"] |> first`, "null"},
+		{`len(1)`, "argument to `len` not supported, got = INTEGER"},
+		{`first(true)`, "argument to `first` must be ARRAY, got BOOLEAN"},
+		{`sum("")`, "argument to `sum` must be ARRAY, got STRING"},
+		{`count(1, fn(x) { true })`, "first argument to `count` must be ARRAY, got INTEGER"},
+		{`each(1, fn(x) { x })`, "first argument to `each` must be ARRAY, HASH or SET, got INTEGER"},
+		{`concat("a", null)`, "argument 2 to `concat` must be STRING like the first, got NULL"},
+		{`size([])`, "argument to `size` must be SET, got ARRAY"},
+		{`toArray(1)`, "argument to `toArray` must be GENERATOR, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestExpect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`expect(5, "INTEGER")`, "5"},
+		{`expect("five", "STRING")`, "five"},
+		{`expect(null, "NULL")`, "null"},
+		{`expect([1, 2], "ARRAY") |> len`, "2"},
+		{`expect(len, "BUILTIN")`, "builtin function"},
+		{`let half = fn(n) { expect(n, "INTEGER") / 2 }; half(10)`, "5"},
+		{`let half = fn(n) { expect(n, "INTEGER") / 2 }; half("10")`, "expected INTEGER, got STRING (10)"},
+		{`expect(1.5, "INTEGER")`, "expected INTEGER, got FLOAT (1.5)"},
+		{`expect([1], "HASH")`, "expected HASH, got ARRAY ([1])"},
+		{`expect(5, "integer")`, `unknown type "integer" given to ` + "`expect`"},
+		{`expect(5, "NUMBER")`, `unknown type "NUMBER" given to ` + "`expect`"},
+		{`expect(5, 5)`, "second argument to `expect` must be STRING, got INTEGER"},
+		{`expect(5)`, "wrong number of arguments. got = 1, want = 2"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestIfWithoutElseInArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = if (false) { 1 }; x + 1`, "type mismatch: NULL + INTEGER"},
+		{`let x = if (false) { 1 }; 2 * x`, "type mismatch: INTEGER * NULL"},
+		{`let x = if (false) { 1 }; -x`, "unknown operator: -NULL"},
+		{`let x = if (true) { let y = 1 }; x - 1.5`, "type mismatch: NULL - FLOAT"},
+		{`let f = fn(n) { if (n > 0) { n } }; f(0) + f(1)`, "type mismatch: NULL + INTEGER"},
+		{`let x = if (false) { 1 }; x == null`, "true"},
+		{`let x = if (false) { 1 }; [x, x]`, "[null, null]"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`entries({})`, "[]"},
+		{`entries({"b": 2, "a": 1, "c": 3})`, "[[a, 1], [b, 2], [c, 3]]"},
+		{`entries({2: "two", 10: "ten", true: "yes"})`, "[[10, ten], [2, two], [true, yes]]"},
+		{`entries({"xs": [1, 2]})`, "[[xs, [1, 2]]]"},
+		{`entries(null)`, "[]"},
+		{`let join = fn(pair) { pair[0] + pair[1] }; entries({"a": "x"}) |> first |> join`, "ax"},
+		{`let h = {"a": 1, "b": 2}; partition(entries(h), fn(pair) { pair[1] > 1 })`, "[[[b, 2]], [[a, 1]]]"},
+		{`entries([1, 2])`, "argument to `entries` must be HASH, got ARRAY"},
+		{`entries()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
@@ -0,0 +1,28 @@
+package evaluator
+
+import (
+	"github.com/kahvecikaan/monkey-lang/object"
+	"github.com/kahvecikaan/monkey-lang/resolver"
+	"sort"
+)
+
+// freeVars returns the sorted names fn's body looks up in the environment fn was defined in. A
+// name is local once a parameter, or a let or enum in an enclosing block of the body, binds it, so
+// a use before the let still counts as free. Builtins fn doesn't shadow aren't captured from
+// anywhere and are left out, as are the locals of functions nested in the body.
+func freeVars(fn *object.Function) []string {
+	found := make(map[string]bool)
+	for _, ident := range resolver.FreeIdentifiers(fn.Parameters, fn.Body) {
+		found[ident.Value] = true
+	}
+
+	names := []string{}
+	for name := range found {
+		if _, ok := fn.Env.Get(name); ok || !IsBuiltin(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,40 @@
+package evaluator
+
+import "testing"
+
+func TestFreeVars(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`freeVars(fn(x) { x })`, "[]"},
+		{`let a = 1; freeVars(fn(x) { x + a })`, "[a]"},
+		{`let adder = fn(x) { fn(y) { x + y } }; freeVars(adder(1))`, "[x]"},
+		{`freeVars(fn(x) { let y = x * 2; y + z })`, "[z]"},
+		{`freeVars(fn() { b + a + b })`, "[a, b]"},
+		// a use before the function's own let still looks the name up outside
+		{`freeVars(fn() { let t = y; let y = 1; y })`, "[y]"},
+		{`freeVars(fn() { if (true) { let t = 1 }; t })`, "[t]"},
+		{`freeVars(fn() { do { let t = 1; t } })`, "[]"},
+		{`freeVars(fn() { with (a = 1, b = a + c) { let t = b; a + t } })`, "[c]"},
+		{`freeVars(fn() { with (a = b, b = 1) { b } })`, "[b]"},
+		{`freeVars(fn() { with (a = 1) { a }; a })`, "[a]"},
+		{`freeVars(fn(x) { guard (x > lo) else { let t = lo; t }; t })`, "[lo, t]"},
+		{`freeVars(fn(n) { let g = fn(m) { m + n + k }; g(1) })`, "[k]"},
+		// nested functions may use locals bound after them
+		{`freeVars(fn() { let g = fn() { h() }; let h = fn() { 1 }; g() })`, "[]"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; freeVars(f)`, "[f]"},
+		{`freeVars(fn(arr) { map(len(arr), fn(x) { x * scale }) })`, "[map, scale]"},
+		{`let len = fn(x) { 0 }; freeVars(fn(arr) { len(arr) })`, "[len]"},
+		{`freeVars(fn(x) { x.double() })`, "[double]"},
+		{`freeVars(fn(h) { {key: h, "k": v}[i] })`, "[i, key, v]"},
+		{`freeVars(fn(a) { a[lo:hi] })`, "[hi, lo]"},
+		{`freeVars(fn() { enum Color { Red }; Color.Red })`, "[]"},
+		{`freeVars(len)`, "argument to `freeVars` must be FUNCTION, got BUILTIN"},
+		{`freeVars()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
@@ -0,0 +1,152 @@
+package evaluator
+
+import (
+	"context"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"runtime"
+	"sync"
+)
+
+// generatorRun runs the function of a generator on a goroutine of its own, which yield parks
+// until the consumer asks for the next value. The goroutine ends with the function, or else when
+// the generator is closed, collected as garbage, or the evaluation that last resumed it is
+// canceled: yield then fails instead of waiting, so the function stops where it is.
+type generatorRun struct {
+	fn   object.Object
+	args []object.Object
+
+	// ev is the evaluation on the generator's goroutine, nil until it's started. It spends the
+	// budget of the evaluation resuming it, which is only changed while the goroutine is parked.
+	ev *evaluation
+	// profiled is the function the generator's side was running when it last yielded, whose
+	// steps it goes back to counting once resumed
+	profiled *FunctionProfile
+	resume   chan struct{}
+	// yields receives every value yielded, then an error if the function fails
+	yields chan object.Object
+	// finished is closed once the goroutine is done
+	finished chan struct{}
+	// stop is closed to end the function, see close
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newGenerator(fn object.Object, args []object.Object) *object.Generator {
+	run := &generatorRun{
+		fn:       fn,
+		args:     args,
+		resume:   make(chan struct{}),
+		yields:   make(chan object.Object),
+		finished: make(chan struct{}),
+		stop:     make(chan struct{}),
+	}
+
+	gen := &object.Generator{Resume: run.next, Stop: run.close}
+	// the goroutine only holds on to run, so a generator nothing refers to anymore can be collected
+	// while its function waits, and that ends the function
+	runtime.SetFinalizer(gen, func(*object.Generator) { run.close() })
+
+	return gen
+}
+
+func (run *generatorRun) next(ctx context.Context) (object.Object, bool) {
+	if run.stopped() {
+		return nil, false
+	}
+
+	consumer := evaluationFrom(ctx)
+	var outerProfiled *FunctionProfile
+	if consumer.profile != nil {
+		outerProfiled = consumer.profile.current
+		consumer.profile.current = run.profiled
+	}
+	defer func() {
+		if consumer.profile != nil {
+			run.profiled = consumer.profile.current
+			consumer.profile.current = outerProfiled
+		}
+	}()
+
+	if run.ev == nil {
+		run.ev = &evaluation{Context: consumer, budget: consumer.budget, generator: run}
+		go run.run()
+	} else {
+		run.ev.Context, run.ev.budget = consumer, consumer.budget
+		select {
+		case run.resume <- struct{}{}:
+		case <-run.finished:
+			return nil, false
+		}
+	}
+
+	select {
+	case value := <-run.yields:
+		return value, true
+	case <-run.finished:
+		return nil, false
+	}
+}
+
+// close makes the function stop, failing the yield it's parked in if it is. Closing a generator
+// more than once, or after its function is done, does nothing.
+func (run *generatorRun) close() {
+	run.stopOnce.Do(func() { close(run.stop) })
+}
+
+func (run *generatorRun) stopped() bool {
+	select {
+	case <-run.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (run *generatorRun) run() {
+	defer close(run.finished)
+	defer func() {
+		// evalProgram's recovery doesn't reach this goroutine
+		if r := recover(); r != nil {
+			run.fail(newError("internal error in generator: %v", r))
+		}
+	}()
+
+	if result := run.ev.applyFunction(run.fn, run.args); isError(result) {
+		run.fail(result)
+	}
+}
+
+// fail hands the error the function ended with to the consumer, unless the generator was stopped
+// and there is no consumer waiting for it
+func (run *generatorRun) fail(errObj object.Object) {
+	select {
+	case run.yields <- errObj:
+	case <-run.stop:
+	}
+}
+
+func (ev *evaluation) evalYield(value object.Object) object.Object {
+	run := ev.generator
+	if run == nil {
+		return newError("yield outside of a generator")
+	}
+
+	// read before yielding, the consumer sets the context of the goroutine for the next resume
+	done := ev.Done()
+	select {
+	case run.yields <- value:
+	case <-run.stop:
+		return newError("generator closed")
+	}
+
+	select {
+	case <-run.resume:
+		return NULL
+	case <-run.stop:
+		return newError("generator closed")
+	case <-done:
+		// no one can resume the generator within the canceled evaluation
+		run.close()
+		return newError("evaluation canceled")
+	}
+}
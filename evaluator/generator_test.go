@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"context"
+	"github.com/kahvecikaan/monkey-lang/lexer"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"github.com/kahvecikaan/monkey-lang/parser"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let g = generator(fn() { yield 1; yield 2; }); [next(g), next(g), next(g)]`, "[1, 2, null]"},
+		{`let nats = fn(i) { yield i; nats(i + 1) }; let g = generator(nats, 5); [next(g), next(g), next(g)]`,
+			"[5, 6, 7]"},
+		{`let g = generator(fn() { yield 1 }); [done(g), next(g), done(g), next(g), done(g)]`,
+			"[false, 1, true, null, true]"},
+		{`let g = generator(fn() { 1 }); [done(g), next(g)]`, "[true, null]"},
+		{`let g = generator(fn() { yield null; yield 2 }); [next(g), done(g), next(g)]`, "[null, false, 2]"},
+		{`let g = generator(fn() { let x = yield 1; yield x }); [next(g), next(g)]`, "[1, null]"},
+		{`let g = generator(fn() { yield 1; return 5; yield 2 }); [next(g), next(g)]`, "[1, null]"},
+		{`let g = generator(fn() { yield 1; 1 + true }); next(g); next(g)`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let g = generator(fn() { 1 + true }); done(g)`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let g = generator(fn() { 1 + true }); next(g); next(g)`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let g = generator(fn() { 1 + true }); let x = next(g); 1`, "type mismatch: INTEGER + BOOLEAN"},
+		{`let evens = fn(g) { let x = next(g); if (x / 2 * 2 == x) { yield x }; evens(g) };
+		  let nats = fn(i) { yield i; nats(i + 1) };
+		  let g = generator(evens, generator(nats, 1));
+		  [next(g), next(g), next(g)]`, "[2, 4, 6]"},
+		{`let g = generator(fn() { yield generator(fn() { yield 1 }) }); next(next(g))`, "1"},
+		{`let lazy = generator(fn() { yield 1 + true }); 1`, "1"},
+		{`yield 1`, "yield outside of a generator"},
+		{`let f = fn() { yield 1 }; f()`, "yield outside of a generator"},
+		{`generator(fn() { yield 1 })`, "generator"},
+		{`generator(1)`, "first argument to `generator` must be FUNCTION or BUILTIN, got INTEGER"},
+		{`generator()`, "wrong number of arguments. got = 0, want at least 1"},
+		{`next([1])`, "argument to `next` must be GENERATOR, got ARRAY"},
+		{`done()`, "wrong number of arguments. got = 0, want = 1"},
+		{`let nats = fn(i) { yield i; nats(i + 1) }; let g = generator(nats, 0);
+		  next(g); close(g); [next(g), done(g)]`, "[null, true]"},
+		{`let g = generator(fn() { yield 1 }); close(g); close(g); next(g)`, "null"},
+		{`let g = generator(fn() { yield 1 }); done(g); close(g); next(g)`, "null"},
+		{`close([1])`, "argument to `close` must be GENERATOR, got ARRAY"},
+		// recursion deep enough to overflow the goroutine's stack fails instead
+		{`let nats = fn(i) { yield i; nats(i + 1) }; len(take(generator(nats, 0), 20000))`,
+			"maximum call depth exceeded: 10000"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
+
+func TestTakeAndToArray(t *testing.T) {
+	nats := `let nats = fn(i) { yield i; nats(i + 1) }; `
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{nats + `take(generator(nats, 0), 3)`, "[0, 1, 2]"},
+		{nats + `let g = generator(nats, 0); take(g, 2); take(g, 2)`, "[2, 3]"},
+		{nats + `take(generator(nats, 0), 0)`, "[]"},
+		{`take(generator(fn() { yield 1 }), 5)`, "[1]"},
+		{`toArray(generator(fn() { yield 1; yield 2; yield 3 }))`, "[1, 2, 3]"},
+		{`let g = generator(fn() { yield 1; yield 2 }); next(g); toArray(g)`, "[2]"},
+		{`let g = generator(fn() { yield 1 }); toArray(g); toArray(g)`, "[]"},
+		{`toArray(generator(fn() { yield 1; 1 + true }))`, "type mismatch: INTEGER + BOOLEAN"},
+		{`take([1], 1)`, "first argument to `take` must be GENERATOR, got ARRAY"},
+		{`take(generator(fn() { 1 }), "a")`, "second argument to `take` must be INTEGER, got STRING"},
+		{`take(generator(fn() { 1 }), -1)`, "second argument to `take` must not be negative, got -1"},
+		{`toArray([1])`, "argument to `toArray` must be GENERATOR, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+
+	// an infinite generator runs until a limit stops it
+	ctx := WithAllocationLimit(context.Background(), 100)
+	evaluated := testEvalContext(ctx, `let ones = fn() { yield 1; ones() }; toArray(generator(ones))`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "allocation limit exceeded: 100" {
+		t.Errorf("infinite generator not stopped by the allocation limit. got = %s", evaluated.Inspect())
+	}
+}
+
+func TestGeneratorFromHost(t *testing.T) {
+	gen, ok := testEval(`let nats = fn(i) { yield i; nats(i + 1) }; generator(nats, 1)`).(*object.Generator)
+	if !ok {
+		t.Fatalf("object is not Generator")
+	}
+	defer gen.Close()
+
+	// resumed from Go, the function runs as an evaluation of its own
+	for _, expected := range []int64{1, 2, 3} {
+		value, ok := gen.Next(context.Background())
+		if !ok {
+			t.Fatalf("generator finished early")
+		}
+		testIntegerObject(t, value, expected)
+	}
+}
+
+func TestGeneratorGoroutinesEnd(t *testing.T) {
+	nats := `let nats = fn(i) { yield i; nats(i + 1) }; `
+	before := runtime.NumGoroutine()
+
+	testEval(nats + `let g = generator(nats, 0); take(g, 3); close(g)`)
+	waitForGoroutines(t, before, "closed generator")
+
+	// nothing refers to the generator after take
+	testEval(nats + `take(generator(nats, 0), 3)`)
+	waitForGoroutines(t, before, "abandoned generator")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(nats + `let g = generator(nats, 0); take(g, 3)`)).ParseProgram()
+	EvalContext(ctx, program, env)
+	cancel()
+	waitForGoroutines(t, before, "generator of a canceled evaluation")
+}
+
+// waitForGoroutines waits for the number of goroutines to get back down to n, collecting garbage
+// so the finalizers of abandoned generators run
+func waitForGoroutines(t *testing.T, n int, what string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine of %s still running: %d goroutines, want %d", what, runtime.NumGoroutine(), n)
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
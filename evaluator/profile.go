@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/kahvecikaan/monkey-lang/ast"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"sort"
+	"strings"
+)
+
+// Profile tallies the work done by an evaluation started with WithProfile: the nodes evaluated
+// overall, and for each function the calls made to it and the nodes evaluated in its body. The
+// closures made by one function literal count as one function.
+type Profile struct {
+	// Steps counts every node evaluated, inside functions or not
+	Steps int
+
+	functions map[*ast.BlockStatement]*FunctionProfile
+	// current is the function whose body is being evaluated, nil at the top level
+	current *FunctionProfile
+}
+
+// FunctionProfile is the part of a Profile about one function. Its Steps only count the nodes of
+// its own body, not those of the functions it calls, so a function calling itself is counted
+// once for each node.
+type FunctionProfile struct {
+	Name string // the let-bound name, empty for anonymous functions
+	// Line and Column locate the opening brace of the body
+	Line, Column int
+	Calls        int
+	Steps        int
+}
+
+func NewProfile() *Profile {
+	return &Profile{functions: make(map[*ast.BlockStatement]*FunctionProfile)}
+}
+
+// Functions returns the functions called so far, the ones the most nodes were evaluated in
+// first
+func (p *Profile) Functions() []*FunctionProfile {
+	functions := make([]*FunctionProfile, 0, len(p.functions))
+	for _, f := range p.functions {
+		functions = append(functions, f)
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		if a.Steps != b.Steps {
+			return a.Steps > b.Steps
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return functions
+}
+
+// Report lays the profile out as a table, one function per line in the order Functions returns
+// them, followed by the total number of steps
+func (p *Profile) Report() string {
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "%10s %10s  %s\n", "calls", "steps", "function")
+	for _, f := range p.Functions() {
+		name := f.Name
+		if name == "" {
+			name = "fn"
+		}
+		fmt.Fprintf(&out, "%10d %10d  %s at line %d, col %d\n", f.Calls, f.Steps, name, f.Line, f.Column)
+	}
+	fmt.Fprintf(&out, "%d steps in total\n", p.Steps)
+
+	return out.String()
+}
+
+func (p *Profile) countStep() {
+	p.Steps++
+	if p.current != nil {
+		p.current.Steps++
+	}
+}
+
+// enter counts a call to fn and attributes the steps to it until leave is called with the
+// function enter returns
+func (p *Profile) enter(fn *object.Function) *FunctionProfile {
+	f, ok := p.functions[fn.Body]
+	if !ok {
+		f = &FunctionProfile{Name: fn.Name, Line: fn.Body.Token.Line, Column: fn.Body.Token.Column}
+		p.functions[fn.Body] = f
+	}
+	f.Calls++
+
+	outer := p.current
+	p.current = f
+	return outer
+}
+
+func (p *Profile) leave(outer *FunctionProfile) {
+	p.current = outer
+}
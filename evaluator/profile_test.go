@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let double = fn(x) { x * 2 };
+count([1, 2, 3], fn(x) { double(x) > 2 });
+fib(5)`
+
+	profile := NewProfile()
+	testIntegerObject(t, testEvalContext(WithProfile(context.Background(), profile), input), 5)
+
+	functions := profile.Functions()
+	if len(functions) != 3 {
+		t.Fatalf("wrong number of functions. got=%d", len(functions))
+	}
+
+	expected := []struct {
+		name         string
+		line, column int
+		calls        int
+	}{
+		{"fib", 2, 17, 15},
+		{"", 4, 24, 3},
+		{"double", 3, 20, 3},
+	}
+	for i, tt := range expected {
+		f := functions[i]
+		if f.Name != tt.name || f.Line != tt.line || f.Column != tt.column || f.Calls != tt.calls {
+			t.Errorf("functions[%d] wrong. expected=%s at %d:%d called %d times, got=%+v",
+				i, tt.name, tt.line, tt.column, tt.calls, f)
+		}
+	}
+
+	// the steps in the functions a function calls aren't its own, and the top level has some too
+	total := 0
+	for i, f := range functions {
+		if f.Steps <= 0 {
+			t.Errorf("functions[%d] has no steps", i)
+		}
+		total += f.Steps
+	}
+	if total >= profile.Steps {
+		t.Errorf("function steps add up to %d, not fewer than the total %d", total, profile.Steps)
+	}
+
+	// the profile is only used by the evaluation it was given to
+	before := profile.Steps
+	testIntegerObject(t, testEvalContext(context.Background(), input), 5)
+	testIntegerObject(t, testEval(input), 5)
+	if profile.Steps != before {
+		t.Errorf("profile counted an evaluation it wasn't given to")
+	}
+}
+
+func TestProfileGenerators(t *testing.T) {
+	// the steps of a generator's function are its own, even though they run between the
+	// consumer's
+	input := `
+let gen = generator(fn() { yield 1; yield 2; yield 3 });
+let consume = fn(g) { next(g) + next(g) + next(g) };
+consume(gen)`
+
+	profile := NewProfile()
+	testIntegerObject(t, testEvalContext(WithProfile(context.Background(), profile), input), 6)
+
+	steps := map[string]int{}
+	for _, f := range profile.Functions() {
+		steps[f.Name] = f.Steps
+	}
+
+	// fn() { yield 1; yield 2; yield 3 }: the body, three statements, three yields, three values
+	if steps[""] != 10 {
+		t.Errorf("wrong steps for the generator's function. got=%d", steps[""])
+	}
+	// fn(g) { next(g) + next(g) + next(g) }: the body, the statement, two infixes, and three
+	// calls, each with its function and argument
+	if steps["consume"] != 13 {
+		t.Errorf("wrong steps for consume. got=%d", steps["consume"])
+	}
+}
+
+func TestProfileReport(t *testing.T) {
+	profile := NewProfile()
+	testEvalContext(WithProfile(context.Background(), profile), "let f = fn() { 1 }; f(); f()")
+
+	expected := "     calls      steps  function\n" +
+		"         2          6  f at line 1, col 14\n" +
+		"15 steps in total\n"
+	if profile.Report() != expected {
+		t.Errorf("wrong report. expected=%q, got=%q", expected, profile.Report())
+	}
+}
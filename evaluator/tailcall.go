@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"github.com/kahvecikaan/monkey-lang/ast"
+	"github.com/kahvecikaan/monkey-lang/object"
+)
+
+// isTailRecursive reports whether fn calls itself by its let-bound name, and only ever as the last
+// thing it does: as the value of its body, of a branch of an if in tail position, or of a return.
+// It's a static check of the body, the evaluator doesn't optimize tail calls.
+func isTailRecursive(fn *object.Function) bool {
+	if fn.Name == "" {
+		return false
+	}
+	for _, param := range fn.Parameters {
+		if param.Value == fn.Name {
+			// the parameter shadows the function, so it can't call itself
+			return false
+		}
+	}
+
+	t := &tailCalls{name: fn.Name}
+	t.tailBlock(fn.Body)
+
+	return t.tail > 0 && !t.nonTail
+}
+
+// tailCalls walks a function body, counting the calls the function makes to itself in tail
+// position and noting whether it makes any elsewhere
+type tailCalls struct {
+	name    string
+	tail    int
+	nonTail bool
+}
+
+func (t *tailCalls) tailBlock(block *ast.BlockStatement) {
+	for i, stmt := range block.Statements {
+		last := i == len(block.Statements)-1
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && last {
+			t.tailExpression(es.Expression)
+		} else {
+			t.inspect(stmt)
+		}
+	}
+}
+
+func (t *tailCalls) tailExpression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.IfExpression:
+		t.inspect(exp.Condition)
+		t.tailBlock(exp.Consequence)
+		if exp.Alternative != nil {
+			t.tailBlock(exp.Alternative)
+		}
+	case *ast.DoExpression:
+		t.tailBlock(exp.Body)
+	case *ast.WithExpression:
+		t.expressions(exp.Values)
+		t.tailBlock(exp.Body)
+	case *ast.CallExpression:
+		if t.isSelf(exp.Function) {
+			t.tail++
+			t.expressions(exp.Arguments)
+			return
+		}
+		t.inspect(exp)
+	case *ast.InfixExpression:
+		if exp.Operator != "|>" {
+			t.inspect(exp)
+			return
+		}
+
+		// x |> f and x |> f(y) call f last
+		switch right := exp.Right.(type) {
+		case *ast.Identifier:
+			if t.isSelf(right) {
+				t.tail++
+				t.inspect(exp.Left)
+				return
+			}
+		case *ast.CallExpression:
+			if t.isSelf(right.Function) {
+				t.tail++
+				t.inspect(exp.Left)
+				t.expressions(right.Arguments)
+				return
+			}
+		}
+		t.inspect(exp)
+	default:
+		t.inspect(exp)
+	}
+}
+
+// inspect looks for uses of the function outside of tail position in node
+func (t *tailCalls) inspect(node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			// calling the function anywhere else, or handing it to something that may call it
+			if t.isSelf(node) {
+				t.nonTail = true
+			}
+		case *ast.LetStatement:
+			t.inspect(node.Value)
+			return false
+		case *ast.EnumStatement:
+			return false
+		case *ast.ReturnStatement:
+			// returns leave the function, from do and with blocks as well, so they're in tail position
+			if node.ReturnValue != nil {
+				t.tailExpression(node.ReturnValue)
+			}
+			return false
+		case *ast.ReturnExpression:
+			if node.ReturnValue != nil {
+				t.tailExpression(node.ReturnValue)
+			}
+			return false
+		case *ast.GuardStatement:
+			t.inspect(node.Condition)
+			// the else block's value is returned
+			t.tailBlock(node.Alternative)
+			return false
+		case *ast.WithExpression:
+			t.expressions(node.Values)
+			t.inspect(node.Body)
+			return false
+		case *ast.MemberExpression:
+			t.inspect(node.Object)
+			return false
+		case *ast.FunctionLiteral:
+			// function literals are other functions
+			return false
+		}
+
+		return true
+	})
+}
+
+func (t *tailCalls) expressions(exps []ast.Expression) {
+	for _, e := range exps {
+		t.inspect(e)
+	}
+}
+
+func (t *tailCalls) isSelf(exp ast.Expression) bool {
+	ident, ok := exp.(*ast.Identifier)
+	return ok && ident.Value == t.name
+}
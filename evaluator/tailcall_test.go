@@ -0,0 +1,42 @@
+package evaluator
+
+import "testing"
+
+func TestIsTailRecursive(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n, acc) { if (n == 0) { return acc; } f(n - 1, acc + n) }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { if (n < 1) { 0 } else if (n < 2) { f(0) } else { return f(n - 2); } }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { let m = n - 1; do { f(m) } }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { n - 1 |> f } }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { n |> f(1) } }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { n * f(n - 1) } }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { if (n < 2) { n } else { f(n - 1) + f(n - 2) } }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(f(n - 1)) } }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { if (f(0)) { 0 } else { f(n - 1) } }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { f(n - 1); 0 }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { let x = do { return f(n); }; x }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { with (m = n - 1) { f(m) } }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { let x = with (m = n) { return f(m); }; x }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { with (m = f(n)) { m } }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { n == 0 || return f(n - 1); 0 }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { guard (n == 0) else { f(n - 1) }; 0 }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { guard (f(n)) else { 1 }; 0 }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { let x = do { n == 0 || return f(n - 1) }; x }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { let g = fn() { f(n) }; f(n) }; isTailRecursive(f)`, "true"},
+		{`let f = fn(n) { map([n], f) }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { n.f() }; isTailRecursive(f)`, "false"},
+		{`let f = fn(n) { n + 1 }; isTailRecursive(f)`, "false"},
+		{`let f = fn(f) { f(1) }; isTailRecursive(f)`, "false"},
+		{`isTailRecursive(fn(n) { n })`, "false"},
+		{`isTailRecursive(len)`, "argument to `isTailRecursive` must be FUNCTION, got BUILTIN"},
+		{`isTailRecursive()`, "wrong number of arguments. got = 0, want = 1"},
+	}
+
+	for _, tt := range tests {
+		testInspected(t, tt.input, tt.expected)
+	}
+}
@@ -1 +1,3 @@
 module github.com/kahvecikaan/monkey-lang
+
+go 1.27
@@ -0,0 +1,123 @@
+package interp
+
+import (
+	"container/list"
+	"errors"
+	"github.com/kahvecikaan/monkey-lang/ast"
+	"github.com/kahvecikaan/monkey-lang/lexer"
+	"github.com/kahvecikaan/monkey-lang/parser"
+	"os"
+	"sync"
+	"time"
+)
+
+// ParseFile parses the Monkey file at path, returning the program or the parser errors, or the
+// error reading the file. Use a Cache to not parse a file that's loaded over and over again.
+func ParseFile(path string) (*ast.Program, []error, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	program, errs := parse(string(src))
+	return program, errs, nil
+}
+
+func parse(src string) (*ast.Program, []error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	var errs []error
+	for _, msg := range p.Errors() {
+		errs = append(errs, errors.New(msg))
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return program, nil
+}
+
+// parsedFile is a program parsed from a file, along with what the file looked like then
+type parsedFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	program *ast.Program
+	errs    []error
+}
+
+// Cache holds the programs parsed from the files most recently loaded through it. It's safe to use
+// from several goroutines.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	// files holds a *parsedFile for every cached path, most recently used first
+	files  *list.List
+	byPath map[string]*list.Element
+}
+
+// NewCache returns a Cache holding on to the programs of up to capacity files, dropping the least
+// recently used one to make room for another
+func NewCache(capacity int) *Cache {
+	return &Cache{capacity: capacity, files: list.New(), byPath: make(map[string]*list.Element)}
+}
+
+// ParseFile parses the file at path like the function ParseFile does, reusing the result of an
+// earlier call for as long as the file's modification time and size stay the same. Parser errors
+// are cached the same way. The program is shared between the callers and must not be changed;
+// evaluating it doesn't change it.
+func (c *Cache) ParseFile(path string) (*ast.Program, []error, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.byPath[path]; ok {
+		cached := elem.Value.(*parsedFile)
+		if cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			c.files.MoveToFront(elem)
+			c.mu.Unlock()
+			return cached.program, cached.errs, nil
+		}
+	}
+	c.mu.Unlock()
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	program, errs := parse(string(src))
+
+	// the file may have changed again since the stat, in which case its next modification time
+	// won't match and it's parsed anew
+	c.add(&parsedFile{path: path, modTime: info.ModTime(), size: info.Size(), program: program, errs: errs})
+
+	return program, errs, nil
+}
+
+func (c *Cache) add(file *parsedFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.byPath[file.path]; ok {
+		elem.Value = file
+		c.files.MoveToFront(elem)
+		return
+	}
+
+	c.byPath[file.path] = c.files.PushFront(file)
+	for c.files.Len() > c.capacity {
+		oldest := c.files.Back()
+		c.files.Remove(oldest)
+		delete(c.byPath, oldest.Value.(*parsedFile).path)
+	}
+}
+
+// Len returns the number of files c holds the programs of
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.files.Len()
+}
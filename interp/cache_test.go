@@ -0,0 +1,109 @@
+package interp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "double.monkey")
+	writeFile := func(src string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeFile("let double = fn(x) { x * 2 };", modTime)
+
+	cache := NewCache(2)
+	first, errs, err := cache.ParseFile(path)
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v, %v", err, errs)
+	}
+
+	again, _, _ := cache.ParseFile(path)
+	if again != first {
+		t.Errorf("unchanged file parsed again")
+	}
+
+	// the same size and a new modification time
+	writeFile("let triple = fn(x) { x * 3 };", modTime.Add(time.Second))
+	changed, _, _ := cache.ParseFile(path)
+	if changed == first || changed.String() != "let triple = fn(x) (x * 3);" {
+		t.Errorf("changed file not parsed again. got=%q", changed.String())
+	}
+
+	// a new size and the same modification time
+	writeFile("let triple = fn(x) { x + x + x };", modTime.Add(time.Second))
+	resized, _, _ := cache.ParseFile(path)
+	if resized == changed || resized.String() != "let triple = fn(x) ((x + x) + x);" {
+		t.Errorf("resized file not parsed again. got=%q", resized.String())
+	}
+
+	writeFile("let = 1;", modTime.Add(2*time.Second))
+	program, errs, err := cache.ParseFile(path)
+	if program != nil || err != nil || len(errs) != 1 ||
+		errs[0].Error() != "expected next token to be IDENT, got =" {
+		t.Errorf("wrong result for a file that doesn't parse. got=%v, %v, %v", program, errs, err)
+	}
+
+	if _, _, err := cache.ParseFile(filepath.Join(t.TempDir(), "missing.monkey")); !os.IsNotExist(err) {
+		t.Errorf("wrong error for a missing file. got=%v", err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{}
+	for _, name := range []string{"a.monkey", "b.monkey", "c.monkey"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("1;"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	cache := NewCache(2)
+	a, _, _ := cache.ParseFile(paths[0])
+	b, _, _ := cache.ParseFile(paths[1])
+	// a is used again, which leaves b the least recently used one when c comes in
+	if again, _, _ := cache.ParseFile(paths[0]); again != a {
+		t.Errorf("cached file parsed again")
+	}
+	cache.ParseFile(paths[2])
+
+	if cache.Len() != 2 {
+		t.Errorf("wrong number of cached files. expected=2, got=%d", cache.Len())
+	}
+	if again, _, _ := cache.ParseFile(paths[0]); again != a {
+		t.Errorf("recently used file dropped from the cache")
+	}
+	if again, _, _ := cache.ParseFile(paths[1]); again == b {
+		t.Errorf("least recently used file kept in the cache")
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "double.monkey")
+	if err := os.WriteFile(path, []byte("let double = fn(x) { x * 2 };"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, errs, err := ParseFile(path)
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v, %v", err, errs)
+	}
+	if again, _, _ := ParseFile(path); again == first {
+		t.Errorf("ParseFile reused an earlier program")
+	}
+
+	if _, _, err := ParseFile(filepath.Join(t.TempDir(), "missing.monkey")); !os.IsNotExist(err) {
+		t.Errorf("wrong error for a missing file. got=%v", err)
+	}
+}
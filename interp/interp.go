@@ -0,0 +1,48 @@
+// Package interp runs Monkey source in a single call, for programs embedding the language.
+package interp
+
+import (
+	"context"
+	"errors"
+	"github.com/kahvecikaan/monkey-lang/evaluator"
+	"github.com/kahvecikaan/monkey-lang/lexer"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"github.com/kahvecikaan/monkey-lang/parser"
+)
+
+// Run parses and evaluates src in env, creating a fresh environment when env is nil. Bindings
+// made by src stay in env, so consecutive runs build on each other the way REPL lines do.
+//
+// When src doesn't parse, Run evaluates nothing and returns every parser error. A runtime error
+// is returned as the only error, the *object.Error itself, whose Error method says where it
+// happened. Either way the returned object is nil: it's only set on success, to the value of the
+// last statement, or to nil when that statement has no value (e.g. let).
+func Run(src string, env *object.Environment) (object.Object, []error) {
+	return RunContext(context.Background(), src, env)
+}
+
+// RunContext is Run evaluating src with evaluator.EvalContext, so the cancellation, limits and
+// profiling set up in ctx apply to it. Calls from several goroutines run at the same time, so
+// they mustn't share an environment.
+func RunContext(ctx context.Context, src string, env *object.Environment) (object.Object, []error) {
+	if env == nil {
+		env = object.NewEnvironment()
+	}
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		errs := make([]error, len(p.Errors()))
+		for i, msg := range p.Errors() {
+			errs[i] = errors.New(msg)
+		}
+		return nil, errs
+	}
+
+	result := evaluator.EvalContext(ctx, program, env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, []error{errObj}
+	}
+
+	return result, nil
+}
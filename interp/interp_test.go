@@ -0,0 +1,115 @@
+package interp
+
+import (
+	"context"
+	"github.com/kahvecikaan/monkey-lang/evaluator"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	result, errs := Run("let double = fn(x) { x * 2 }; double(21)", nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestRunKeepsBindings(t *testing.T) {
+	env := object.NewEnvironment()
+
+	result, errs := Run("let a = 1;", env)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result != nil {
+		t.Errorf("let statement has a result. got=%s", result.Inspect())
+	}
+
+	result, errs = Run("a + 1", env)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result.Inspect() != "2" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let = 1; let x 2;", []string{
+			"expected next token to be IDENT, got =",
+			"expected next token to be =, got INT",
+		}},
+		{"1 + true", []string{"at line 1, col 3: type mismatch: INTEGER + BOOLEAN"}},
+		{"missing", []string{"at line 1, col 1: identifier not found: missing"}},
+	}
+
+	for _, tt := range tests {
+		result, errs := Run(tt.input, nil)
+		if result != nil {
+			t.Errorf("result returned alongside errors for %q. got=%s", tt.input, result.Inspect())
+		}
+
+		if len(errs) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%v", tt.input, tt.expected, errs)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errs[i].Error() != msg {
+				t.Errorf("wrong error %d for %q. expected=%q, got=%q", i, tt.input, msg, errs[i])
+			}
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx := evaluator.WithStepLimit(context.Background(), 10)
+
+	_, errs := RunContext(ctx, "let f = fn(n) { f(n + 1) }; f(0)", nil)
+	if len(errs) != 1 || errs[0].Error() != "at line 1, col 17: step limit exceeded: 10" {
+		t.Errorf("wrong errors. got=%v", errs)
+	}
+
+	result, errs := RunContext(ctx, "1 + 2", nil)
+	if len(errs) != 0 || result.Inspect() != "3" {
+		t.Errorf("wrong result. got=%v, %v", result, errs)
+	}
+}
+
+func TestRunContextConcurrently(t *testing.T) {
+	limited := evaluator.WithStepLimit(context.Background(), 1000)
+	src := "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(200)"
+
+	var wg sync.WaitGroup
+	errs := make([][]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := context.Background()
+			if i%2 == 0 {
+				ctx = limited
+			}
+			_, errs[i] = RunContext(ctx, src, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	// each evaluation keeps its own limit and step count
+	for i, got := range errs {
+		if i%2 == 0 && (len(got) != 1 || !strings.HasSuffix(got[0].Error(), "step limit exceeded: 1000")) {
+			t.Errorf("limited run %d: wrong errors. got=%v", i, got)
+		}
+		if i%2 == 1 && len(got) != 0 {
+			t.Errorf("unlimited run %d: unexpected errors: %v", i, got)
+		}
+	}
+}
@@ -7,15 +7,23 @@ type Lexer struct {
 	position     int  // current position in input (points to currentChar)
 	readPosition int  // current reading position in input (after currentChar)
 	ch           byte // current char under examination (ONLY SUPPORTS ASCII characters as it's byte!)
+	line         int  // line of currentChar
+	column       int  // column of currentChar
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // "NUL" which indicates either the start or EOF
 	} else {
@@ -26,10 +34,38 @@ func (l *Lexer) readChar() {
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line, tok.Column = line, column
+	tok.EndLine, tok.EndColumn = l.line, l.column
+	if tok.Type == token.EOF {
+		// EOF has no characters, it ends where it starts
+		tok.EndLine, tok.EndColumn = line, column
+	}
+
+	return tok
+}
+
+// Tokens reads the rest of the input, returning its tokens up to and including the EOF token. Tools
+// that only need tokens can use it instead of calling NextToken until EOF. Illegal characters
+// become ILLEGAL tokens rather than stopping it.
+func (l *Lexer) Tokens() []token.Token {
+	tokens := []token.Token{}
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// readToken reads the token starting at the current character
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -57,6 +93,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -80,12 +118,21 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			return token.Token{Type: token.OR, Literal: lit}
 		}
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			lit := string(ch) + string(l.ch)
+			l.readChar()
+			return token.Token{Type: token.PIPE, Literal: lit}
+		}
 		tok := token.Token{Type: token.ILLEGAL, Literal: string(l.ch)}
 		l.readChar()
 		return tok
 
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
 	case ';':
@@ -114,8 +161,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookUpIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -130,20 +176,55 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// readIdentifier reads a letter followed by any letters and digits, so names like sha256 are one
+// identifier
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a float when the digits are followed by a fraction, an exponent
+// or both. A fraction needs digits after the point, so 1.len() is still a method call on 1.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	var tokenType token.TokenType = token.INT
+
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if (l.ch == 'e' || l.ch == 'E') && l.exponentFollows() {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+}
+
+// exponentFollows reports whether the 'e' under examination starts an exponent, being followed by
+// digits with an optional sign
+func (l *Lexer) exponentFollows() bool {
+	next := l.readPosition
+	if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+		next++
+	}
+
+	return next < len(l.input) && isDigit(l.input[next])
 }
 
 func (l *Lexer) readString() string {
@@ -166,9 +247,19 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// skipWhitespace skips whitespace and line comments, which run from "//" to the end of the line
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+		default:
+			return
+		}
 	}
 }
 
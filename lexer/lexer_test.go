@@ -174,6 +174,38 @@ true && true || false;
 	}
 }
 
+func TestPipeOperatorTokenizing(t *testing.T) {
+	input := `x |> f || |>>`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.OR, "||"},
+		{token.PIPE, "|>"},
+		{token.GT, ">"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected = %q, got = %q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected = %q, got = %q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
 func TestLogicalOperatorTokenizing(t *testing.T) {
 	input := `&& || & | &&& |||`
 
@@ -207,3 +239,199 @@ func TestLogicalOperatorTokenizing(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+if (x) {
+	"a b" + y
+}`
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IF, 2, 1},
+		{token.LPAREN, 2, 4},
+		{token.IDENT, 2, 5},
+		{token.RPAREN, 2, 6},
+		{token.LBRACE, 2, 8},
+		{token.STRING, 3, 2},
+		{token.PLUS, 3, 8},
+		{token.IDENT, 3, 10},
+		{token.RBRACE, 4, 1},
+		{token.EOF, 4, 2},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected = %q, got = %q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected = %d:%d, got = %d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestTokenEnds(t *testing.T) {
+	input := `let name = "a
+b"; 12.5e3 == x`
+
+	tests := []struct {
+		expectedType      token.TokenType
+		expectedEndLine   int
+		expectedEndColumn int
+	}{
+		{token.LET, 1, 4},
+		{token.IDENT, 1, 9},
+		{token.ASSIGN, 1, 11},
+		// the closing quote is part of the token, and so are the lines in between
+		{token.STRING, 2, 3},
+		{token.SEMICOLON, 2, 4},
+		{token.FLOAT, 2, 11},
+		{token.EQ, 2, 14},
+		{token.IDENT, 2, 16},
+		{token.EOF, 2, 16},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected = %q, got = %q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.EndLine != tt.expectedEndLine || tok.EndColumn != tt.expectedEndColumn {
+			t.Fatalf("tests[%d] - end wrong. expected = %d:%d, got = %d:%d",
+				i, tt.expectedEndLine, tt.expectedEndColumn, tok.EndLine, tok.EndColumn)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 10 / 2; // trailing comment
+// a comment // with slashes
+x
+//`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected = %q, got = %q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected = %q, got = %q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumberTokenizing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"1e10", []token.Token{{Type: token.FLOAT, Literal: "1e10"}}},
+		{"1.5e-3", []token.Token{{Type: token.FLOAT, Literal: "1.5e-3"}}},
+		{"2E+8", []token.Token{{Type: token.FLOAT, Literal: "2E+8"}}},
+		{"0.25", []token.Token{{Type: token.FLOAT, Literal: "0.25"}}},
+		{"42", []token.Token{{Type: token.INT, Literal: "42"}}},
+		// a point without digits after it is a method call or member access
+		{"1.len", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.DOT, Literal: "."},
+			{Type: token.IDENT, Literal: "len"},
+		}},
+		// an e without digits after it isn't an exponent
+		{"1e", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.IDENT, Literal: "e"},
+		}},
+		{"1e-x", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.IDENT, Literal: "e"},
+			{Type: token.MINUS, Literal: "-"},
+			{Type: token.IDENT, Literal: "x"},
+		}},
+		// digits continue an identifier but can't start one
+		{"sha256", []token.Token{{Type: token.IDENT, Literal: "sha256"}}},
+		{"x1_y2", []token.Token{{Type: token.IDENT, Literal: "x1_y2"}}},
+		{"2x", []token.Token{
+			{Type: token.INT, Literal: "2"},
+			{Type: token.IDENT, Literal: "x"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range append(tt.expected, token.Token{Type: token.EOF}) {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("%q tokens[%d] wrong. expected = %s %q, got = %s %q",
+					tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	tokens := New("let x = 1.5;\n@x").Tokens()
+
+	expected := []token.Token{
+		{Type: token.LET, Literal: "let", Line: 1, Column: 1, EndLine: 1, EndColumn: 4},
+		{Type: token.IDENT, Literal: "x", Line: 1, Column: 5, EndLine: 1, EndColumn: 6},
+		{Type: token.ASSIGN, Literal: "=", Line: 1, Column: 7, EndLine: 1, EndColumn: 8},
+		{Type: token.FLOAT, Literal: "1.5", Line: 1, Column: 9, EndLine: 1, EndColumn: 12},
+		{Type: token.SEMICOLON, Literal: ";", Line: 1, Column: 12, EndLine: 1, EndColumn: 13},
+		{Type: token.ILLEGAL, Literal: "@", Line: 2, Column: 1, EndLine: 2, EndColumn: 2},
+		{Type: token.IDENT, Literal: "x", Line: 2, Column: 2, EndLine: 2, EndColumn: 3},
+		{Type: token.EOF, Literal: "", Line: 2, Column: 3, EndLine: 2, EndColumn: 3},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected = %d, got = %d (%v)", len(expected), len(tokens), tokens)
+	}
+	for i, tok := range tokens {
+		if tok != expected[i] {
+			t.Errorf("tokens[%d] wrong. expected = %+v, got = %+v", i, expected[i], tok)
+		}
+	}
+
+	// an empty input is just the EOF
+	if tokens := New("").Tokens(); len(tokens) != 1 || tokens[0].Type != token.EOF {
+		t.Errorf("wrong tokens for empty input. got = %v", tokens)
+	}
+}
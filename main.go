@@ -3,17 +3,57 @@ package main
 import (
 	"fmt"
 	"github.com/kahvecikaan/monkey-lang/repl"
+	"io"
 	"os"
 	"os/user"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: monkey test FILE")
+			os.Exit(2)
+		}
+		os.Exit(runTests(os.Args[2], os.Stdout))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "--profile" {
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: monkey --profile FILE")
+			os.Exit(2)
+		}
+		os.Exit(runProfiled(os.Args[2], os.Stdout, os.Stderr))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "-e" {
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: monkey -e SOURCE")
+			os.Exit(2)
+		}
+		os.Exit(runSource(os.Args[2], true, os.Stdout, os.Stderr))
+	}
+
+	// a program piped or redirected in runs as a whole, only a terminal gets the REPL
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(runSource(string(src), false, os.Stdout, os.Stderr))
+	}
+
 	usr, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s, this is the Monkey programming language!\n",
 		usr.Username)
-	fmt.Printf("Feel free to type any commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	fmt.Printf("Feel free to type any commands, or :help to see what's available\n")
+	// colors are only for a terminal, not for output redirected to a file
+	color := false
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		color = true
+	}
+	repl.Start(os.Stdin, os.Stdout, repl.WithColor(color))
 }
@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -29,3 +31,50 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Binding is a name bound in an environment and its value
+type Binding struct {
+	Name  string
+	Value Object
+}
+
+// Bindings lists the local bindings of e sorted by name, leaving out those of enclosing
+// environments
+func (e *Environment) Bindings() []Binding {
+	bindings := make([]Binding, 0, len(e.store))
+	for name, val := range e.store {
+		bindings = append(bindings, Binding{Name: name, Value: val})
+	}
+
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Name < bindings[j].Name })
+
+	return bindings
+}
+
+// Snapshot holds a copy of an environment's local bindings, see Environment.Snapshot
+type Snapshot struct {
+	store map[string]Object
+}
+
+// Snapshot copies the local bindings of e so they can be brought back with Restore. The bindings
+// of enclosing environments aren't part of it, and neither are changes made inside the bound
+// objects themselves: the values are shared, not copied.
+func (e *Environment) Snapshot() *Snapshot {
+	store := make(map[string]Object, len(e.store))
+	for name, val := range e.store {
+		store[name] = val
+	}
+
+	return &Snapshot{store: store}
+}
+
+// Restore resets the local bindings of e to the ones in s, dropping any made since the snapshot
+// was taken. A snapshot can be restored more than once.
+func (e *Environment) Restore(s *Snapshot) {
+	store := make(map[string]Object, len(s.store))
+	for name, val := range s.store {
+		store[name] = val
+	}
+
+	e.store = store
+}
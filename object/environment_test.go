@@ -0,0 +1,80 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentSnapshotRestore(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("global", NewInteger(1))
+
+	env := NewEnclosedEnvironment(outer)
+	env.Set("a", NewInteger(1))
+	env.Set("b", NewInteger(2))
+
+	snapshot := env.Snapshot()
+
+	env.Set("a", NewInteger(10))
+	env.Set("c", NewInteger(3))
+	outer.Set("global", NewInteger(100))
+
+	env.Restore(snapshot)
+
+	expectBinding(t, env, "a", "1")
+	expectBinding(t, env, "b", "2")
+	if _, ok := env.Get("c"); ok {
+		t.Errorf("binding made after the snapshot survived the restore")
+	}
+
+	// only the local bindings are snapshotted
+	expectBinding(t, env, "global", "100")
+
+	// restoring doesn't tie the environment to the snapshot
+	env.Set("d", NewInteger(4))
+	env.Restore(snapshot)
+	if _, ok := env.Get("d"); ok {
+		t.Errorf("binding made after the first restore survived the second")
+	}
+	expectBinding(t, env, "a", "1")
+}
+
+func expectBinding(t *testing.T, env *Environment, name, expected string) {
+	t.Helper()
+
+	val, ok := env.Get(name)
+	if !ok {
+		t.Errorf("%s is not bound", name)
+		return
+	}
+	if val.Inspect() != expected {
+		t.Errorf("%s has wrong value. got = %s, want = %s", name, val.Inspect(), expected)
+	}
+}
+
+func TestEnvironmentBindings(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("global", NewInteger(1))
+
+	env := NewEnclosedEnvironment(outer)
+	env.Set("b", NewInteger(2))
+	env.Set("a", NewString("x"))
+	env.Set("c", TRUE)
+	env.Set("a", NewInteger(1))
+
+	bindings := env.Bindings()
+
+	got := []string{}
+	for _, binding := range bindings {
+		got = append(got, binding.Name+"="+binding.Value.Inspect())
+	}
+
+	expected := "a=1 b=2 c=true"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("wrong bindings. expected = %q, got = %q", expected, got)
+	}
+
+	if len(NewEnvironment().Bindings()) != 0 {
+		t.Errorf("new environment has bindings")
+	}
+}
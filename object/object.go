@@ -2,9 +2,14 @@ package object
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/kahvecikaan/monkey-lang/ast"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +17,8 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BIGINT_OBJ       = "BIGINT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -21,11 +28,28 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	SET_OBJ          = "SET"
+	ENUM_OBJ         = "ENUM"
+	ENUM_VALUE_OBJ   = "ENUM_VALUE"
+	GENERATOR_OBJ    = "GENERATOR"
 )
 
+var objectTypes = map[ObjectType]bool{
+	INTEGER_OBJ: true, FLOAT_OBJ: true, BIGINT_OBJ: true, BOOLEAN_OBJ: true, NULL_OBJ: true,
+	RETURN_VALUE_OBJ: true, ERROR_OBJ: true, FUNCTION_OBJ: true, STRING_OBJ: true, BUILTIN_OBJ: true,
+	ARRAY_OBJ: true, HASH_OBJ: true, SET_OBJ: true, ENUM_OBJ: true, ENUM_VALUE_OBJ: true,
+	GENERATOR_OBJ: true,
+}
+
+// IsObjectType reports whether name is the type of some kind of object
+func IsObjectType(name string) bool {
+	return objectTypes[ObjectType(name)]
+}
+
 var (
 	TRUE  = &Boolean{Value: true, hashKey: nil}
 	FALSE = &Boolean{Value: false, hashKey: nil}
+	NULL  = &Null{}
 )
 
 type Object interface {
@@ -65,6 +89,56 @@ func NewInteger(value int64) *Integer {
 	return &Integer{Value: value, hashKey: nil}
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	switch {
+	case math.IsInf(f.Value, 1):
+		return "inf"
+	case math.IsInf(f.Value, -1):
+		return "-inf"
+	case math.IsNaN(f.Value):
+		return "nan"
+	}
+
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	// Keep a decimal point so floats with integral values aren't mistaken for integers
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+func NewFloat(value float64) *Float {
+	return &Float{Value: value}
+}
+
+// BigInt is an arbitrary-precision integer. Its Value is never modified once the BigInt exists,
+// arithmetic creates new ones.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+
+// HashKey is the key of the integer with the same value if there is one, so that a bigint finds
+// the integer equal to it in a hash or set, and the other way around
+func (b *BigInt) HashKey() HashKey {
+	if b.Value.IsInt64() {
+		return NewInteger(b.Value.Int64()).HashKey()
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+func NewBigInt(value *big.Int) *BigInt {
+	return &BigInt{Value: value}
+}
+
 type Boolean struct {
 	Value   bool
 	hashKey *HashKey //Private field to store the cached hash key
@@ -103,6 +177,10 @@ type Null struct{}
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
+func (n *Null) HashKey() HashKey {
+	// All nulls are equal, so they share a single fixed hash
+	return HashKey{Type: n.Type(), Value: 0}
+}
 
 type ReturnValue struct {
 	Value Object
@@ -113,15 +191,29 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	// Line and Column locate the node that caused the error, both 0 when it isn't known
+	Line   int
+	Column int
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Error() }
+
+// Error returns the message, preceded by where the error happened when that's known, so an
+// *Error can be handed on as a Go error
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("at line %d, col %d: %s", e.Line, e.Column, e.Message)
+}
 
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment // to allow for closures
+	Name       string       // the let-bound name, empty for anonymous functions
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -134,10 +226,15 @@ func (f *Function) Inspect() string {
 	}
 
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	if f.Body != nil {
+		out.WriteString(f.Body.String())
+	}
 	out.WriteString("\n}")
 
 	return out.String()
@@ -169,8 +266,18 @@ func NewString(value string) *String {
 }
 
 type BuiltinFunction func(args ...Object) Object
+
+// ContextBuiltinFunction is a BuiltinFunction that's also given the context of the evaluation
+// calling it, for builtins that call back into the evaluator
+type ContextBuiltinFunction func(ctx context.Context, args ...Object) Object
+
 type Builtin struct {
-	Fn BuiltinFunction
+	Name string
+	// Doc is the signature and a short description, e.g. "len(x) -> integer: ...", empty if unknown
+	Doc string
+	Fn  BuiltinFunction
+	// FnContext is called instead of Fn when it's set
+	FnContext ContextBuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -181,12 +288,20 @@ type Array struct {
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
-func (ao *Array) Inspect() string {
+func (ao *Array) Inspect() string  { return ao.inspect(newInspecting(nil)) }
+
+func (ao *Array) inspect(seen inspecting) string {
+	if seen.objs[ao] {
+		return "[...]"
+	}
+	seen.objs[ao] = true
+	defer delete(seen.objs, ao)
+
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, seen.inspect(e))
 	}
 
 	out.WriteString("[")
@@ -196,12 +311,64 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// inspecting holds the arrays and hashes being inspected, from the outermost one down. An array or
+// hash that contains itself is printed as [...] or {...} the second time instead of recursing
+// forever. Keys and set members are hashable, so they can't contain anything.
+type inspecting struct {
+	objs     map[Object]bool
+	describe Describe
+}
+
+func newInspecting(describe Describe) inspecting {
+	return inspecting{objs: make(map[Object]bool), describe: describe}
+}
+
+// Describe returns the text standing for h, or false to have h inspected as usual
+type Describe func(h *Hash) (string, bool)
+
+// InspectWith is Inspect with describe asked first about every hash in obj: obj itself, and the
+// hashes nested in arrays and hashes
+func InspectWith(obj Object, describe Describe) string {
+	return newInspecting(describe).inspect(obj)
+}
+
+func (seen inspecting) described(h *Hash) (string, bool) {
+	if seen.describe == nil {
+		return "", false
+	}
+
+	return seen.describe(h)
+}
+
+func (seen inspecting) inspect(obj Object) string {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.inspect(seen)
+	case *Hash:
+		return obj.inspect(seen)
+	default:
+		return obj.Inspect()
+	}
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
 func compareObjects(a, b Object) bool {
+	// an integer and a bigint are the same key when they hold the same value, see BigInt.HashKey
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*BigInt); ok {
+			return b.Value.IsInt64() && b.Value.Int64() == a.Value
+		}
+	case *BigInt:
+		if b, ok := b.(*Integer); ok {
+			return a.Value.IsInt64() && a.Value.Int64() == b.Value
+		}
+	}
+
 	if a.Type() != b.Type() {
 		return false
 	}
@@ -211,8 +378,15 @@ func compareObjects(a, b Object) bool {
 		return a.Value == b.(*String).Value
 	case *Integer:
 		return a.Value == b.(*Integer).Value
+	case *BigInt:
+		return a.Value.Cmp(b.(*BigInt).Value) == 0
 	case *Boolean:
 		return a.Value == b.(*Boolean).Value
+	case *Null:
+		return true
+	case *Function, *Builtin, *EnumValue:
+		// functions and enum values are only equal to themselves
+		return a == b
 	default:
 		return false
 	}
@@ -241,15 +415,27 @@ func NewHash() *Hash {
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
+
+// Inspect lists the pairs sorted by their keys' inspected strings, so the output doesn't depend on
+// the order the map is iterated in. Keys that inspect the same, like 1 and "1", are ordered by type.
+func (h *Hash) Inspect() string { return h.inspect(newInspecting(nil)) }
+
+func (h *Hash) inspect(seen inspecting) string {
+	if seen.objs[h] {
+		return "{...}"
+	}
+	if s, ok := seen.described(h); ok {
+		return s
+	}
+	seen.objs[h] = true
+	defer delete(seen.objs, h)
+
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, chain := range h.Pairs {
-		for _, pair := range chain {
-			pairs = append(pairs, fmt.Sprintf("%s: %s",
-				pair.Key.Inspect(), pair.Value.Inspect()))
-		}
+	for _, pair := range h.SortedPairs() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s",
+			pair.Key.Inspect(), seen.inspect(pair.Value)))
 	}
 
 	out.WriteString("{")
@@ -259,13 +445,31 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// SortedPairs returns the pairs of the hash in the order Inspect lists them
+func (h *Hash) SortedPairs() []HashPair {
+	sorted := []HashPair{}
+	for _, chain := range h.Pairs {
+		sorted = append(sorted, chain...)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].Key, sorted[j].Key
+		if a.Inspect() != b.Inspect() {
+			return a.Inspect() < b.Inspect()
+		}
+		return a.Type() < b.Type()
+	})
+
+	return sorted
+}
+
 // Add adds or updates a key-value pair in the hash table.
 // If the key already exists, its value is updated.
 // If the key hashes to an existing value but is different, it's added to the chain.
 func (h *Hash) Add(key, value Object) error {
 	hashKey, ok := key.(Hashable)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", key.Type())
+		return fmt.Errorf("unusable as hash key: %s (%s)", key.Type(), key.Inspect())
 	}
 
 	hashed := hashKey.HashKey()
@@ -286,3 +490,246 @@ func (h *Hash) Add(key, value Object) error {
 	h.Pairs[hashed] = chain
 	return nil
 }
+
+// Copy returns a hash with the same pairs, which can be changed without changing h
+func (h *Hash) Copy() *Hash {
+	hash := NewHash()
+	for hashed, chain := range h.Pairs {
+		// Add replaces values within a chain, so the chains can't be shared
+		hash.Pairs[hashed] = append(HashChain(nil), chain...)
+	}
+
+	return hash
+}
+
+// Set holds distinct objects, hashed and compared the same way as the keys of a Hash
+type Set struct {
+	Elements map[HashKey][]Object
+}
+
+func NewSet() *Set {
+	return &Set{Elements: make(map[HashKey][]Object)}
+}
+
+func (s *Set) Type() ObjectType { return SET_OBJ }
+
+// Inspect lists the members sorted by their inspected strings, so equal sets print the same
+func (s *Set) Inspect() string {
+	var out bytes.Buffer
+
+	members := []string{}
+	for _, m := range s.Members() {
+		members = append(members, m.Inspect())
+	}
+
+	out.WriteString("set([")
+	out.WriteString(strings.Join(members, ", "))
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// Add adds obj to the set unless an equal object is already a member.
+func (s *Set) Add(obj Object) error {
+	hashable, ok := obj.(Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as set element: %s (%s)", obj.Type(), obj.Inspect())
+	}
+
+	hashed := hashable.HashKey()
+	chain := s.Elements[hashed]
+	for _, m := range chain {
+		if compareObjects(m, obj) {
+			return nil
+		}
+	}
+
+	// capping the chain makes append copy it, so copies sharing the old one are left alone
+	s.Elements[hashed] = append(chain[:len(chain):len(chain)], obj)
+	return nil
+}
+
+// Has reports whether an object equal to obj is a member. Unhashable objects never are.
+func (s *Set) Has(obj Object) bool {
+	hashable, ok := obj.(Hashable)
+	if !ok {
+		return false
+	}
+
+	for _, m := range s.Elements[hashable.HashKey()] {
+		if compareObjects(m, obj) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Set) Remove(obj Object) {
+	hashable, ok := obj.(Hashable)
+	if !ok {
+		return
+	}
+
+	hashed := hashable.HashKey()
+	chain := s.Elements[hashed]
+	for i, m := range chain {
+		if compareObjects(m, obj) {
+			// build a new chain so copies sharing the old one are left alone
+			rest := make([]Object, 0, len(chain)-1)
+			rest = append(rest, chain[:i]...)
+			rest = append(rest, chain[i+1:]...)
+			if len(rest) == 0 {
+				delete(s.Elements, hashed)
+			} else {
+				s.Elements[hashed] = rest
+			}
+			return
+		}
+	}
+}
+
+func (s *Set) Len() int {
+	length := 0
+	for _, chain := range s.Elements {
+		length += len(chain)
+	}
+
+	return length
+}
+
+// Members returns the members of the set, in the same order Inspect lists them.
+func (s *Set) Members() []Object {
+	members := []Object{}
+	for _, chain := range s.Elements {
+		members = append(members, chain...)
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Inspect() < members[j].Inspect()
+	})
+
+	return members
+}
+
+// Copy returns a new set with the same members.
+func (s *Set) Copy() *Set {
+	set := NewSet()
+	for hashed, chain := range s.Elements {
+		set.Elements[hashed] = chain
+	}
+
+	return set
+}
+
+// Enum is the object bound by an enum declaration, holding one EnumValue per member
+type Enum struct {
+	Name    string
+	Members []*EnumValue
+}
+
+func NewEnum(name string, tags []string) *Enum {
+	enum := &Enum{Name: name}
+	for i, tag := range tags {
+		enum.Members = append(enum.Members, &EnumValue{Enum: enum, Tag: tag, Index: i})
+	}
+
+	return enum
+}
+
+func (e *Enum) Type() ObjectType { return ENUM_OBJ }
+func (e *Enum) Inspect() string {
+	tags := []string{}
+	for _, m := range e.Members {
+		tags = append(tags, m.Tag)
+	}
+
+	return "enum " + e.Name + " { " + strings.Join(tags, ", ") + " }"
+}
+
+func (e *Enum) Member(tag string) (*EnumValue, bool) {
+	for _, m := range e.Members {
+		if m.Tag == tag {
+			return m, true
+		}
+	}
+
+	return nil, false
+}
+
+// EnumValue is a member of an enum. Each one is a singleton, so values compare by identity:
+// members of two enums declared alike are still different.
+type EnumValue struct {
+	Enum  *Enum
+	Tag   string
+	Index int // position in the declaration
+}
+
+func (ev *EnumValue) Type() ObjectType { return ENUM_VALUE_OBJ }
+func (ev *EnumValue) Inspect() string  { return ev.Enum.Name + "." + ev.Tag }
+
+// HashKey hashes the qualified name, values of enums declared alike collide and are told apart
+// by identity
+func (ev *EnumValue) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(ev.Inspect()))
+	return HashKey{Type: ev.Type(), Value: h.Sum64()}
+}
+
+// Generator produces the values yielded by a function running alongside the code consuming them.
+// Running the function is up to the evaluator: Resume continues it up to its next yield, as part of
+// the evaluation ctx belongs to, returning the value yielded, or false once the function has
+// finished. Only one evaluation may resume a generator at a time.
+type Generator struct {
+	Resume func(ctx context.Context) (Object, bool)
+	// Stop ends the function where it's waiting to be resumed, nil if there's nothing to end
+	Stop func()
+
+	// the value Peek resumed the function for, waiting to be returned by Next
+	peeked    Object
+	hasPeeked bool
+	finished  bool
+}
+
+func (g *Generator) Type() ObjectType { return GENERATOR_OBJ }
+func (g *Generator) Inspect() string  { return "generator" }
+
+// Next returns the next value of the generator, or false once there are no more
+func (g *Generator) Next(ctx context.Context) (Object, bool) {
+	if value, ok := g.Peek(ctx); ok {
+		g.peeked, g.hasPeeked = nil, false
+		return value, true
+	}
+
+	return nil, false
+}
+
+// Close ends the generator before its function finishes: Next and Peek return no more values,
+// and the function stops where it's waiting to be resumed
+func (g *Generator) Close() {
+	g.peeked, g.hasPeeked = nil, false
+	g.finished = true
+	if g.Stop != nil {
+		g.Stop()
+	}
+}
+
+// Peek returns the value Next will return without consuming it, running the function up to its
+// next yield if needed
+func (g *Generator) Peek(ctx context.Context) (Object, bool) {
+	if g.hasPeeked {
+		return g.peeked, true
+	}
+	if g.finished {
+		return nil, false
+	}
+
+	value, ok := g.Resume(ctx)
+	if !ok {
+		g.finished = true
+		return nil, false
+	}
+
+	g.peeked, g.hasPeeked = value, true
+	return value, true
+}
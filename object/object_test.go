@@ -40,6 +40,102 @@ func TestBooleanHashKey(t *testing.T) {
 	}
 }
 
+func TestNullHashKey(t *testing.T) {
+	null1 := &Null{}
+	null2 := &Null{}
+
+	if null1.HashKey() != null2.HashKey() {
+		t.Errorf("nulls do not have same hash key")
+	}
+
+	if null1.HashKey() == FALSE.HashKey() {
+		t.Errorf("null has same hash key as false")
+	}
+
+	if null1.HashKey() == NewInteger(0).HashKey() {
+		t.Errorf("null has same hash key as 0")
+	}
+
+	hash := NewHash()
+	if err := hash.Add(null1, NewInteger(1)); err != nil {
+		t.Fatalf("error adding null key: %s", err)
+	}
+	if err := hash.Add(null2, NewInteger(2)); err != nil {
+		t.Fatalf("error updating null key: %s", err)
+	}
+
+	chain := hash.Pairs[NULL.HashKey()]
+	if len(chain) != 1 {
+		t.Fatalf("nulls were not treated as the same key. chain length = %d", len(chain))
+	}
+	pair, found := chain.FindPair(NULL)
+	if !found {
+		t.Fatalf("null key not found")
+	}
+	if pair.Value.Inspect() != "2" {
+		t.Errorf("wrong value for null key. got = %s, want = 2", pair.Value.Inspect())
+	}
+}
+
+func TestSetCopiesAreIndependent(t *testing.T) {
+	set := NewSet()
+	set.Add(NewInteger(1))
+
+	copied := set.Copy()
+	copied.Add(NewInteger(2))
+	copied.Remove(NewInteger(1))
+
+	if set.Len() != 1 || !set.Has(NewInteger(1)) || set.Has(NewInteger(2)) {
+		t.Errorf("changing a copy changed the original. got = %s", set.Inspect())
+	}
+	if copied.Len() != 1 || copied.Has(NewInteger(1)) || !copied.Has(NewInteger(2)) {
+		t.Errorf("copy has wrong members. got = %s", copied.Inspect())
+	}
+
+	if err := set.Add(&Array{}); err == nil {
+		t.Errorf("expected error when adding unhashable element, got nil")
+	}
+	if set.Has(&Array{}) {
+		t.Errorf("set unexpectedly has an unhashable element")
+	}
+}
+
+func TestHashInspectIsSorted(t *testing.T) {
+	hash := NewHash()
+	hash.Add(NewString("b"), NewInteger(2))
+	hash.Add(NewInteger(1), NewString("one"))
+	hash.Add(NewString("a"), NewInteger(1))
+	hash.Add(TRUE, NULL)
+	hash.Add(NewString("1"), NewString("string one"))
+
+	expected := "{1: one, 1: string one, a: 1, b: 2, true: null}"
+	for i := 0; i < 10; i++ {
+		if hash.Inspect() != expected {
+			t.Fatalf("wrong hash inspect. expected = %q, got = %q", expected, hash.Inspect())
+		}
+	}
+
+	if NewHash().Inspect() != "{}" {
+		t.Errorf("wrong empty hash inspect. got = %q", NewHash().Inspect())
+	}
+}
+
+func TestCompareFunctions(t *testing.T) {
+	f := &Function{}
+	g := &Function{}
+	b := &Builtin{}
+
+	if !compareObjects(f, f) || !compareObjects(b, b) {
+		t.Errorf("function or builtin not equal to itself")
+	}
+	if compareObjects(f, g) {
+		t.Errorf("distinct functions compare equal")
+	}
+	if compareObjects(f, b) {
+		t.Errorf("function compares equal to builtin")
+	}
+}
+
 func TestIntegerHashKey(t *testing.T) {
 	one1 := &Integer{Value: 1}
 	one2 := &Integer{Value: 1}
@@ -58,3 +154,69 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestInspectCycles(t *testing.T) {
+	arr := &Array{Elements: []Object{NewInteger(1)}}
+	arr.Elements = append(arr.Elements, arr)
+
+	hash := NewHash()
+	hash.Add(NewString("self"), hash)
+	hash.Add(NewString("arr"), arr)
+
+	// a value referenced twice without a cycle is printed in full both times
+	shared := &Array{Elements: []Object{NewInteger(2)}}
+	twice := &Array{Elements: []Object{shared, shared}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{arr, "[1, [...]]"},
+		{hash, "{arr: [1, [...]], self: {...}}"},
+		{&Array{Elements: []Object{hash}}, "[{arr: [1, [...]], self: {...}}]"},
+		{twice, "[[2], [2]]"},
+	}
+
+	for _, tt := range tests {
+		if tt.obj.Inspect() != tt.expected {
+			t.Errorf("wrong inspect. expected = %q, got = %q", tt.expected, tt.obj.Inspect())
+		}
+	}
+}
+
+func TestInspectWith(t *testing.T) {
+	point := NewHash()
+	point.Add(NewString("x"), NewInteger(1))
+
+	// a hash the describe function skips is inspected as usual, with what it holds still described
+	holder := NewHash()
+	holder.Add(NewString("p"), point)
+	holder.Add(NewString("self"), holder)
+
+	describe := func(h *Hash) (string, bool) {
+		if h == point {
+			return "point", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		obj      Object
+		expected string
+		pretty   string
+	}{
+		{point, "point", "point"},
+		{&Array{Elements: []Object{point, NewInteger(2)}}, "[point, 2]", "[\n  point,\n  2\n]"},
+		{holder, "{p: point, self: {...}}", "{\n  p: point,\n  self: {...}\n}"},
+		{&Array{Elements: []Object{&Array{}, point}}, "[[], point]", "[\n  [],\n  point\n]"},
+	}
+
+	for _, tt := range tests {
+		if got := InspectWith(tt.obj, describe); got != tt.expected {
+			t.Errorf("wrong inspect. expected = %q, got = %q", tt.expected, got)
+		}
+		if got := PrettyInspectWith(tt.obj, describe); got != tt.pretty {
+			t.Errorf("wrong pretty inspect. expected = %q, got = %q", tt.pretty, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package object
+
+import "strings"
+
+const prettyIndent = "  "
+
+// PrettyInspect is Inspect spread over several lines: arrays, hashes and sets holding other
+// collections get one indented line per element, while those holding only scalars stay on one
+// line the way Inspect prints them.
+func PrettyInspect(obj Object) string {
+	return PrettyInspectWith(obj, nil)
+}
+
+// PrettyInspectWith is PrettyInspect with describe asked first about every hash, like InspectWith
+func PrettyInspectWith(obj Object, describe Describe) string {
+	var out strings.Builder
+	writePretty(&out, obj, "", newInspecting(describe))
+	return out.String()
+}
+
+func writePretty(out *strings.Builder, obj Object, indent string, seen inspecting) {
+	// cycle is printed when obj contains itself
+	var open, close, cycle string
+	var items []Object
+	var keys []Object
+
+	switch obj := obj.(type) {
+	case *Array:
+		open, close, cycle = "[", "]", "[...]"
+		items = obj.Elements
+	case *Hash:
+		open, close, cycle = "{", "}", "{...}"
+		for _, pair := range obj.SortedPairs() {
+			keys = append(keys, pair.Key)
+			items = append(items, pair.Value)
+		}
+	case *Set:
+		open, close = "set([", "])"
+		items = obj.Members()
+	default:
+		out.WriteString(obj.Inspect())
+		return
+	}
+
+	if seen.objs[obj] {
+		out.WriteString(cycle)
+		return
+	}
+	if hash, ok := obj.(*Hash); ok {
+		if s, ok := seen.described(hash); ok {
+			out.WriteString(s)
+			return
+		}
+	}
+	seen.objs[obj] = true
+	defer delete(seen.objs, obj)
+
+	if !containsCollection(items) {
+		out.WriteString(obj.Inspect())
+		return
+	}
+
+	inner := indent + prettyIndent
+	out.WriteString(open + "\n")
+	for i, item := range items {
+		out.WriteString(inner)
+		if keys != nil {
+			out.WriteString(keys[i].Inspect() + ": ")
+		}
+		writePretty(out, item, inner, seen)
+		if i < len(items)-1 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString(indent + close)
+}
+
+func containsCollection(items []Object) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case *Array, *Hash, *Set:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+package object
+
+import "testing"
+
+func TestPrettyInspect(t *testing.T) {
+	hash := NewHash()
+	hash.Add(NewString("name"), NewString("monkey"))
+	hash.Add(NewString("tags"), &Array{Elements: []Object{NewString("a"), NewString("b")}})
+
+	nested := NewHash()
+	nested.Add(NewString("inner"), &Array{Elements: []Object{NewInteger(1), &Array{}}})
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{NewInteger(1), "1"},
+		{&Array{}, "[]"},
+		{&Array{Elements: []Object{NewInteger(1), NewInteger(2)}}, "[1, 2]"},
+		{
+			&Array{Elements: []Object{NewInteger(1), &Array{Elements: []Object{NewInteger(2)}}}},
+			"[\n  1,\n  [2]\n]",
+		},
+		{hash, "{\n  name: monkey,\n  tags: [a, b]\n}"},
+		{
+			&Array{Elements: []Object{nested, TRUE}},
+			"[\n  {\n    inner: [\n      1,\n      []\n    ]\n  },\n  true\n]",
+		},
+	}
+
+	for _, tt := range tests {
+		actual := PrettyInspect(tt.obj)
+		if actual != tt.expected {
+			t.Errorf("wrong pretty inspect of %s.\nexpected:\n%s\ngot:\n%s", tt.obj.Inspect(), tt.expected, actual)
+		}
+	}
+}
+
+func TestPrettyInspectCycles(t *testing.T) {
+	arr := &Array{Elements: []Object{NewInteger(1)}}
+	arr.Elements = append(arr.Elements, arr)
+
+	hash := NewHash()
+	hash.Add(NewString("self"), hash)
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{arr, "[\n  1,\n  [...]\n]"},
+		{hash, "{\n  self: {...}\n}"},
+	}
+
+	for _, tt := range tests {
+		if got := PrettyInspect(tt.obj); got != tt.expected {
+			t.Errorf("wrong pretty inspect. expected = %q, got = %q", tt.expected, got)
+		}
+	}
+}
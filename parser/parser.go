@@ -1,25 +1,28 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"github.com/kahvecikaan/monkey-lang/ast"
 	"github.com/kahvecikaan/monkey-lang/lexer"
 	"github.com/kahvecikaan/monkey-lang/token"
+	"sort"
 	"strconv"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	PIPE        // |>
 	LOGICAL_OR  // ||
 	LOGICAL_AND // &&
 	EQUALS      // == or !=
 	LESSGREATER // > or <
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // *, / and %
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
-	INDEX       // array[index]
+	INDEX       // array[index] or receiver.method(X)
 )
 
 var precedences = map[token.TokenType]int{
@@ -31,10 +34,13 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 	token.AND:      LOGICAL_AND,
 	token.OR:       LOGICAL_OR,
+	token.PIPE:     PIPE,
 }
 
 type (
@@ -42,10 +48,41 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression // arg: left side of infix operator that's being parsed
 )
 
+type openConstruct struct {
+	name  string
+	token token.Token // the opening token
+}
+
+var closingTokens = map[token.TokenType]token.TokenType{
+	token.LPAREN:   token.RPAREN,
+	token.LBRACKET: token.RBRACKET,
+	token.LBRACE:   token.RBRACE,
+}
+
 type Parser struct {
 	l      *lexer.Lexer
 	errors []string
 
+	// panicking is set by the first error in a statement and suppresses the errors that follow
+	// from it until the parser has synchronized on the next statement
+	panicking bool
+	// depth counts the braces opened and not yet closed up to and including currToken
+	depth int
+	// constructs holds the bracketed constructs being parsed, innermost last, so that running
+	// into EOF can be reported against the construct that was left open
+	constructs []openConstruct
+	// unterminated is set once the innermost construct left open at EOF has been reported, the
+	// ones enclosing it are left open by the same mistake
+	unterminated bool
+	// negated is set while parsing an integer literal that directly follows a unary minus, where
+	// the magnitude of math.MinInt64 is still in range
+	negated bool
+	// minInt is the literal parsed as math.MinInt64 under the minus being parsed, which is only
+	// right when the literal is the minus's whole operand
+	minInt *ast.IntegerLiteral
+	// functions counts the function bodies being parsed, which guards must be inside of
+	functions int
+
 	currToken token.Token
 	peekToken token.Token
 
@@ -62,13 +99,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.DO, p.parseDoExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
+	p.registerPrefix(token.WITH, p.parseWithExpression)
+	p.registerPrefix(token.RETURN, p.parseReturnExpression)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
@@ -77,6 +120,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
@@ -84,8 +128,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMethodCallExpression)
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
 
 	// Read two tokens so currToken and peakToken are both set
 	p.nextToken()
@@ -96,6 +142,13 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.currToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch {
+	case p.currTokenIs(token.LBRACE):
+		p.depth++
+	case p.currTokenIs(token.RBRACE) && p.depth > 0:
+		p.depth--
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -105,24 +158,59 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for !p.currTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize(0)
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	if len(program.Statements) > 0 {
+		first, last := program.Statements[0], program.Statements[len(program.Statements)-1]
+		program.SetSpan(ast.Span{Start: first.Span().Start, End: last.Span().End})
+	}
+
 	return program
 }
 
+// synchronize implements panic-mode recovery: after an error it skips the rest of the broken
+// statement, so parsing can resume with the next one and report independent errors too. It stops
+// on a ";" at the statement's own nesting level or on the "}" closing the enclosing block, leaving
+// that token as currToken.
+func (p *Parser) synchronize(level int) {
+	for !p.currTokenIs(token.EOF) {
+		if p.currTokenIs(token.SEMICOLON) && p.depth == level {
+			break
+		}
+		if p.currTokenIs(token.RBRACE) && p.depth < level {
+			break
+		}
+		p.nextToken()
+	}
+
+	p.panicking = false
+}
+
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.currToken
+
+	var stmt ast.Statement
 	switch p.currToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
+	case token.GUARD:
+		stmt = p.parseGuardStatement()
+	case token.ENUM:
+		stmt = p.parseEnumStatement()
 	default:
-		return p.parseExpressionStatement()
+		stmt = p.parseExpressionStatement()
 	}
+	p.setSpan(stmt, start)
+
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
@@ -132,7 +220,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	stmt.Name = p.newIdentifier()
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -142,6 +230,61 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	// let name = fn(...) {...} gives the function its name
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && !stmt.Name.IsBlank() {
+		fl.Name = stmt.Name.Value
+	}
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.currToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = p.newIdentifier()
+	if stmt.Name.IsBlank() {
+		// an enum is only used through its name
+		p.addError("_ can't name an enum")
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.enterConstruct("enum")
+	defer p.leaveConstruct()
+
+	seen := map[string]bool{}
+	for !p.panicking && !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		if seen[p.currToken.Literal] {
+			p.addError(fmt.Sprintf("duplicate member %s in enum %s", p.currToken.Literal, stmt.Name.Value))
+			return nil
+		}
+		seen[p.currToken.Literal] = true
+		stmt.Members = append(stmt.Members, p.newIdentifier())
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -152,11 +295,54 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.currToken}
 
+	// a bare return leaves ReturnValue nil
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseGuardStatement() *ast.GuardStatement {
+	stmt := &ast.GuardStatement{Token: p.currToken}
+
+	if p.functions == 0 {
+		p.addError("guard outside of a function")
+		return nil
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.enterConstruct("guard condition")
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		p.leaveConstruct()
+		return nil
+	}
+	p.leaveConstruct()
+
+	if !p.expectPeek(token.ELSE) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Alternative = p.parseBlockStatement()
+
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -169,7 +355,7 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.currToken}
 	stmt.Expression = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) { // optional semicolon
+	if !p.panicking && p.peekTokenIs(token.SEMICOLON) { // optional semicolon
 		p.nextToken()
 	}
 
@@ -179,14 +365,17 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// for debugging purposes
 	// defer untrace(trace("parseExpression"))
+	start := p.currToken
 	prefix := p.prefixParseFns[p.currToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.currToken.Type)
 		return nil
 	}
 	leftExp := prefix()
+	p.setSpan(leftExp, start)
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	for !p.panicking && !p.peekTokenIs(token.SEMICOLON) && !p.peekStartsStatement() &&
+		precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -194,13 +383,28 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 		p.nextToken()
 		leftExp = infix(leftExp)
+		p.setSpan(leftExp, start)
 	}
 
 	return leftExp
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	ident := p.newIdentifier()
+	if ident.IsBlank() {
+		p.addError("_ can only be bound, not used as a value")
+		return nil
+	}
+
+	return ident
+}
+
+// newIdentifier makes an identifier of the current token, spanning just that token
+func (p *Parser) newIdentifier() *ast.Identifier {
+	ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+	ident.SetSpan(ast.TokenSpan(p.currToken))
+
+	return ident
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
@@ -208,10 +412,40 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	// defer untrace(trace("parseIntegerLiteral"))
 	lit := &ast.IntegerLiteral{Token: p.currToken}
 
+	negated := p.negated
+	p.negated = false
+
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
+	if err != nil && errors.Is(err, strconv.ErrRange) && negated {
+		// -9223372036854775808 is stored as math.MinInt64 under the minus, which the evaluator
+		// leaves as it is instead of negating it
+		value, err = strconv.ParseInt("-"+p.currToken.Literal, 0, 64)
+		p.minInt = lit
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		var msg string
+		if errors.Is(err, strconv.ErrRange) {
+			msg = fmt.Sprintf("integer literal out of range: %s", p.currToken.Literal)
+		} else {
+			msg = fmt.Sprintf("could not parse %q as integer", p.currToken.Literal)
+		}
+		p.addError(msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.currToken}
+
+	value, err := strconv.ParseFloat(p.currToken.Literal, 64)
+	if err != nil {
+		// the lexer only produces well-formed literals, so this is a value past the float64 range.
+		// Ones too small to represent become 0, which ParseFloat doesn't report
+		p.addError(fmt.Sprintf("float literal out of range: %s", p.currToken.Literal))
 		return nil
 	}
 
@@ -231,7 +465,18 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	// advance to next token!
 	p.nextToken()
 
+	// minInt belongs to an enclosing minus until this one's operand is parsed
+	outerMinInt := p.minInt
+	p.minInt = nil
+	defer func() { p.minInt = outerMinInt }()
+
+	p.negated = expression.Operator == "-" && p.currTokenIs(token.INT)
 	expression.Right = p.parseExpression(PREFIX)
+	if p.minInt != nil && expression.Right != ast.Expression(p.minInt) {
+		// in -9223372036854775808.f() the minus negates the call, not the literal
+		p.addError(fmt.Sprintf("integer literal out of range: %s", p.minInt.Token.Literal))
+		return nil
+	}
 
 	return expression
 }
@@ -249,14 +494,40 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
+	p.checkChainedComparison(expression)
+
 	return expression
 }
 
+// checkChainedComparison reports comparisons like 1 < x < 10, which compare the boolean 1 < x
+// with 10 rather than testing that x is between the bounds. Booleans aren't ordered, so such a
+// comparison can only fail, even with the inner one in parentheses.
+func (p *Parser) checkChainedComparison(expression *ast.InfixExpression) {
+	inner, ok := expression.Left.(*ast.InfixExpression)
+	if !ok || !isOrdering(inner.Operator) || !isOrdering(expression.Operator) || expression.Right == nil {
+		return
+	}
+
+	p.addError(fmt.Sprintf("comparisons can't be chained: did you mean %s %s %s && %s %s %s?",
+		inner.Left, inner.Operator, inner.Right, inner.Right, expression.Operator, expression.Right))
+}
+
+func isOrdering(operator string) bool {
+	return operator == "<" || operator == ">"
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.currToken, Value: p.currTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.currToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.enterConstruct("parenthesized expression")
+	defer p.leaveConstruct()
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -274,13 +545,16 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		return nil
 	}
 
+	p.enterConstruct("if condition")
 	// jump over "(" token
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
+		p.leaveConstruct()
 		return nil
 	}
+	p.leaveConstruct()
 
 	// same logic here
 	if !p.expectPeek(token.LBRACE) {
@@ -290,9 +564,30 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	// currToken sits on "{"
 	expression.Consequence = p.parseBlockStatement()
 
+	if p.peekTokenIs(token.ELIF) {
+		// elif is short for else if, so it's parsed as the if it stands for and builds the same
+		// chain
+		p.nextToken()
+		p.currToken.Type = token.IF
+		expression.Alternative = p.parseElseIf()
+		if expression.Alternative == nil {
+			return nil
+		}
+		return expression
+	}
+
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			expression.Alternative = p.parseElseIf()
+			if expression.Alternative == nil {
+				return nil
+			}
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -304,20 +599,58 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseElseIf parses the if expression following an else, which is currToken, into the block
+// holding the chained if that ast.IfExpression.ElseIf looks for
+func (p *Parser) parseElseIf() *ast.BlockStatement {
+	tok := p.currToken
+
+	elseIf := p.parseIfExpression()
+	if elseIf == nil {
+		return nil
+	}
+
+	// the block and the statement in it only hold the if, so they all span the same source
+	p.setSpan(elseIf, tok)
+	stmt := &ast.ExpressionStatement{Token: tok, Expression: elseIf}
+	stmt.SetSpan(elseIf.Span())
+	block := &ast.BlockStatement{Token: tok, Statements: []ast.Statement{stmt}}
+	block.SetSpan(elseIf.Span())
+
+	return block
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currToken}
 	block.Statements = []ast.Statement{}
+	level := p.depth
+
+	p.enterConstruct("block")
+	defer p.leaveConstruct()
 
 	p.nextToken()
 
 	for !p.currTokenIs(token.RBRACE) && !p.currTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize(level)
+			if p.currTokenIs(token.RBRACE) {
+				// the broken statement ran into the end of the block
+				continue
+			}
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	if p.currTokenIs(token.EOF) {
+		p.unterminatedError()
+	}
+	block.SetSpan(ast.Span{
+		Start: ast.TokenSpan(block.Token).Start,
+		End:   ast.TokenSpan(p.currToken).End,
+	})
+
 	return block
 }
 
@@ -334,14 +667,106 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	p.functions++
 	lit.Body = p.parseBlockStatement()
+	p.functions--
 
 	return lit
 }
 
+func (p *Parser) parseDoExpression() ast.Expression {
+	expression := &ast.DoExpression{Token: p.currToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	return expression
+}
+
+func (p *Parser) parseWithExpression() ast.Expression {
+	expression := &ast.WithExpression{Token: p.currToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.enterConstruct("binding list")
+	for !p.panicking && !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			break
+		}
+		name := p.newIdentifier()
+
+		if !p.expectPeek(token.ASSIGN) {
+			break
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		// like let, with (name = fn(...) {...}) gives the function its name
+		if fl, ok := value.(*ast.FunctionLiteral); ok && !name.IsBlank() {
+			fl.Name = name.Value
+		}
+		expression.Names = append(expression.Names, name)
+		expression.Values = append(expression.Values, value)
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			break
+		}
+	}
+	p.leaveConstruct()
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if len(expression.Names) == 0 {
+		p.addError("with needs at least one binding")
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	return expression
+}
+
+// parseReturnExpression parses a return in expression position; one starting a statement is a
+// ReturnStatement. The return is bare when the expression ends right after it.
+func (p *Parser) parseReturnExpression() ast.Expression {
+	expression := &ast.ReturnExpression{Token: p.currToken}
+
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.RBRACE, token.RPAREN, token.RBRACKET, token.COMMA, token.EOF:
+		return expression
+	}
+
+	p.nextToken()
+	expression.ReturnValue = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+func (p *Parser) parseYieldExpression() ast.Expression {
+	expression := &ast.YieldExpression{Token: p.currToken}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
+	p.enterConstruct("parameter list")
+	defer p.leaveConstruct()
+
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
 		return identifiers
@@ -349,14 +774,15 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-	identifiers = append(identifiers, ident)
+	identifiers = append(identifiers, p.newIdentifier())
 
-	for p.peekTokenIs(token.COMMA) {
+	for !p.panicking && p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) { // trailing comma
+			break
+		}
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
-		identifiers = append(identifiers, ident)
+		identifiers = append(identifiers, p.newIdentifier())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -368,13 +794,18 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.currToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseExpressionList(token.RPAREN, "argument list")
 	return exp
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+// parseExpressionList parses comma separated expressions up to end, with name describing the
+// list in errors
+func (p *Parser) parseExpressionList(end token.TokenType, name string) []ast.Expression {
 	list := []ast.Expression{}
 
+	p.enterConstruct(name)
+	defer p.leaveConstruct()
+
 	if p.peekTokenIs(end) {
 		p.nextToken()
 		return list
@@ -383,8 +814,11 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	p.nextToken()
 	list = append(list, p.parseExpression(LOWEST))
 
-	for p.peekTokenIs(token.COMMA) {
+	for !p.panicking && p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		if p.peekTokenIs(end) { // trailing comma
+			break
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -431,7 +865,7 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currToken}
 
-	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Elements = p.parseExpressionList(token.RBRACKET, "array")
 
 	return array
 }
@@ -440,7 +874,10 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.currToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
-	for !p.peekTokenIs(token.RBRACE) {
+	p.enterConstruct("hash")
+	defer p.leaveConstruct()
+
+	for !p.panicking && !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
 
@@ -452,6 +889,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -465,12 +903,25 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	return hash
 }
 
+// parseIndexExpression parses both left[index] and the slice left[start:end:step]
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
 
+	p.enterConstruct("index expression")
+	defer p.leaveConstruct()
+
 	p.nextToken()
+	if p.currTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, nil)
+	}
+
 	exp.Index = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
+
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
@@ -478,6 +929,77 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseSliceExpression parses the rest of a slice after its first ':', which is currToken
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(LOWEST)
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseMethodCallExpression parses both receiver.method(args) and, without the parentheses, the
+// member access receiver.member
+func (p *Parser) parseMethodCallExpression(receiver ast.Expression) ast.Expression {
+	exp := &ast.MethodCallExpression{Token: p.currToken, Receiver: receiver}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Method = p.newIdentifier()
+
+	if !p.peekTokenIs(token.LPAREN) {
+		return &ast.MemberExpression{Token: exp.Token, Object: receiver, Member: exp.Method}
+	}
+	p.nextToken()
+
+	exp.Arguments = p.parseExpressionList(token.RPAREN, "argument list")
+
+	return exp
+}
+
+// peekStartsStatement makes semicolons optional at the end of a line. Most statements end there
+// anyway since the next line's first token can't continue them, but "(", "[" and "-" could either
+// continue the expression or start a new one. As the first token on a new line they start one.
+func (p *Parser) peekStartsStatement() bool {
+	if p.peekToken.Line <= p.currToken.Line {
+		return false
+	}
+
+	_, ok := p.prefixParseFns[p.peekToken.Type]
+	return ok
+}
+
+// setSpan gives node the span from start to the current token, the last one parsed for it. A node
+// keeps the span it already has, so an expression in parentheses doesn't take them into its span.
+func (p *Parser) setSpan(node ast.Node, start token.Token) {
+	// nodes that failed to parse may be nil pointers
+	if p.panicking || node == nil || node.Span() != (ast.Span{}) {
+		return
+	}
+
+	node.SetSpan(ast.Span{
+		Start: ast.TokenSpan(start).Start,
+		End:   ast.TokenSpan(p.currToken).End,
+	})
+}
+
 func (p *Parser) currTokenIs(t token.TokenType) bool {
 	return p.currToken.Type == t
 }
@@ -501,10 +1023,25 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// addError records msg unless the parser is already recovering from an earlier error in the
+// same statement, in which case msg is most likely a consequence of that one
+func (p *Parser) addError(msg string) {
+	if p.panicking {
+		return
+	}
+
+	p.errors = append(p.errors, msg)
+	p.panicking = true
+}
+
 func (p *Parser) peekError(t token.TokenType) {
+	if p.peekTokenIs(token.EOF) && p.unterminatedError() {
+		return
+	}
+
 	msg := fmt.Sprintf("expected next token to be %s, got %s",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(msg)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -516,8 +1053,40 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	if t == token.EOF && p.unterminatedError() {
+		return
+	}
+
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(msg)
+}
+
+// enterConstruct records that currToken opens the construct called name
+func (p *Parser) enterConstruct(name string) {
+	p.constructs = append(p.constructs, openConstruct{name: name, token: p.currToken})
+}
+
+func (p *Parser) leaveConstruct() {
+	p.constructs = p.constructs[:len(p.constructs)-1]
+}
+
+// unterminatedError reports the innermost open construct as unterminated, returning false if
+// nothing is open
+func (p *Parser) unterminatedError() bool {
+	if len(p.constructs) == 0 {
+		return false
+	}
+
+	if p.unterminated {
+		return true
+	}
+
+	open := p.constructs[len(p.constructs)-1]
+	msg := fmt.Sprintf("unterminated %s started at line %d: expected '%s' before EOF",
+		open.name, open.token.Line, closingTokens[open.token.Type])
+	p.addError(msg)
+	p.unterminated = true
+	return true
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -535,3 +1104,39 @@ func (p *Parser) currPrecedence() int {
 
 	return LOWEST
 }
+
+// Precedence reports the binding power the parser gives t when it appears as an infix operator.
+// Tokens that aren't infix operators report LOWEST.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+
+	return LOWEST
+}
+
+// PrefixTokens lists the token types that can start an expression, sorted by name
+func (p *Parser) PrefixTokens() []token.TokenType {
+	tokens := make([]token.TokenType, 0, len(p.prefixParseFns))
+	for t := range p.prefixParseFns {
+		tokens = append(tokens, t)
+	}
+
+	return sortTokenTypes(tokens)
+}
+
+// InfixTokens lists the token types that can continue an expression as an infix or postfix
+// operator, sorted by name
+func (p *Parser) InfixTokens() []token.TokenType {
+	tokens := make([]token.TokenType, 0, len(p.infixParseFns))
+	for t := range p.infixParseFns {
+		tokens = append(tokens, t)
+	}
+
+	return sortTokenTypes(tokens)
+}
+
+func sortTokenTypes(tokens []token.TokenType) []token.TokenType {
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	return tokens
+}
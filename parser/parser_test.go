@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"github.com/kahvecikaan/monkey-lang/ast"
 	"github.com/kahvecikaan/monkey-lang/lexer"
+	"github.com/kahvecikaan/monkey-lang/token"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -77,6 +80,118 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestReturnExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x || return 0", "(x || return 0)"},
+		{"let y = x && return f(x) + 1;", "let y = (x && return (f(x) + 1));"},
+		{"[1, return, 3]", "[1, return, 3]"},
+		{"f(return 1, 2)", "f(return 1, 2)"},
+		{"1 + (return)", "(1 + return)"},
+		{"fn(x) { x || return; x }", "fn(x) (x || return)x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	// a return starting a statement is still a return statement
+	program := New(lexer.New("return x || y")).ParseProgram()
+	if _, ok := program.Statements[0].(*ast.ReturnStatement); !ok {
+		t.Errorf("statement not *ast.ReturnStatement. got=%T", program.Statements[0])
+	}
+}
+
+func TestGuardStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x) { guard (x > 0) else { return 0 }; x }", "fn(x) guard(x > 0) else return 0;;x"},
+		{"fn(x) { guard (x) else { 0 } x }", "fn(x) guardx else 0;x"},
+		{"fn() { do { guard (ok) else { } } }", "fn() do guardok else ;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"guard (x) else { 0 }", "guard outside of a function"},
+		{"do { guard (x) else { 0 } }", "guard outside of a function"},
+		{"fn() { 1 }; guard (x) else { 0 }", "guard outside of a function"},
+		{"fn(x) { guard x else { 0 } }", "expected next token to be (, got IDENT"},
+		{"fn(x) { guard (x) { 0 } }", "expected next token to be ELSE, got {"},
+		{"fn(x) { guard (x) else 0 }", "expected next token to be {, got INT"},
+	}
+
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestBareReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return;", "return;"},
+		{"return", "return;"},
+		{"fn() { return }", "fn() return;"},
+		{"fn() { return; }", "fn() return;"},
+		{"fn(x) { if (x) { return; }; x }", "fn(x) ifx return;x"},
+		{"return; 1", "return;1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("return;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
+	}
+	if returnStmt.ReturnValue != nil {
+		t.Errorf("returnStmt.ReturnValue is not nil. got=%T", returnStmt.ReturnValue)
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := `foobar;`
 
@@ -140,6 +255,135 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBounds(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"9223372036854775807", math.MaxInt64},
+		{"0", 0},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		testIntegerLiteral(t, stmt.Expression, tt.expected)
+	}
+
+	// the magnitude of math.MinInt64 is only in range right after a minus
+	p := New(lexer.New("-9223372036854775808"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.PrefixExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.PrefixExpression. got=%T", stmt.Expression)
+	}
+	if exp.Operator != "-" {
+		t.Fatalf("exp.Operator is not '-'. got=%s", exp.Operator)
+	}
+	literal, ok := exp.Right.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp.Right is not ast.IntegerLiteral. got=%T", exp.Right)
+	}
+	if literal.Value != math.MinInt64 {
+		t.Errorf("literal.Value not %d. got=%d", int64(math.MinInt64), literal.Value)
+	}
+	if stmt.String() != "(-9223372036854775808)" {
+		t.Errorf("wrong program string. got=%q", stmt.String())
+	}
+
+	// the minus applies to the literal alone in these
+	for _, input := range []string{
+		"-9223372036854775808 + 1",
+		"f(-9223372036854775808)",
+		"-x.f(-9223372036854775808)",
+		"--9223372036854775808",
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "integer literal out of range: 9223372036854775808"},
+		{"99999999999999999999", "integer literal out of range: 99999999999999999999"},
+		{"-9223372036854775809", "integer literal out of range: 9223372036854775809"},
+		{"-(9223372036854775808)", "integer literal out of range: 9223372036854775808"},
+		{"!9223372036854775808", "integer literal out of range: 9223372036854775808"},
+		{"1 - 9223372036854775808", "integer literal out of range: 9223372036854775808"},
+		{"-9223372036854775808.f()", "integer literal out of range: 9223372036854775808"},
+		{"-9223372036854775808[0]", "integer literal out of range: 9223372036854775808"},
+		{"-9223372036854775808.f(-9223372036854775808)", "integer literal out of range: 9223372036854775808"},
+	}
+
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("wrong number of errors for %q. got=%q", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"1e10", 1e10},
+		{"1.5e-3", 1.5e-3},
+		{"2E+8", 2e8},
+		{"1.7976931348623157e308", math.MaxFloat64},
+		{"5e-324", math.SmallestNonzeroFloat64},
+		// too small to represent, which rounds to zero rather than failing
+		{"1e-400", 0},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+
+	for _, input := range []string{"1e400", "2.5e309"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		expected := "float literal out of range: " + input
+		if len(errors) != 1 || errors[0] != expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", input, expected, errors)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -150,8 +394,8 @@ func TestParsingPrefixExpressions(t *testing.T) {
 		{"-15;", "-", 15},
 		{"!foobar;", "!", "foobar"},
 		{"-foobar;", "-", "foobar"},
-		{"!true", "!", "true"},
-		{"!false", "!", "false"},
+		{"!true", "!", true},
+		{"!false", "!", false},
 	}
 
 	for _, tt := range prefixTests {
@@ -196,6 +440,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
@@ -208,9 +453,9 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"foobar < barfoo;", "foobar", "<", "barfoo"},
 		{"foobar == barfoo;", "foobar", "==", "barfoo"},
 		{"foobar != barfoo;", "foobar", "!=", "barfoo"},
-		{"true == true", "true", "==", "true"},
-		{"true != false", "true", "!=", "false"},
-		{"false == false", "false", "==", "false"},
+		{"true == true", true, "==", true},
+		{"true != false", true, "!=", false},
+		{"false == false", false, "==", false},
 	}
 
 	for _, tt := range infixTests {
@@ -250,6 +495,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!-a",
 			"(!(-a))",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c)",
@@ -402,6 +651,35 @@ func TestBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestNullLiteralExpression(t *testing.T) {
+	input := `null;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got = %d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.ExpressionStatement. got = %T",
+			program.Statements[0])
+	}
+
+	null, ok := stmt.Expression.(*ast.NullLiteral)
+	if !ok {
+		t.Fatalf("stmt not *ast.NullLiteral. got = %T", stmt.Expression)
+	}
+
+	if null.TokenLiteral() != "null" {
+		t.Errorf("null.TokenLiteral not %s. got = %s", "null", null.TokenLiteral())
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 
@@ -510,178 +788,162 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
-func TestFunctionLiteralParsing(t *testing.T) {
-	input := `fn(x, y) { x + y; }`
+func TestElseIfExpression(t *testing.T) {
+	input := `if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }`
+
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	if len(program.Statements) != 1 {
-		t.Errorf("program.Statements does not contain %d statements. got = %d\n",
-			1, len(program.Statements))
-	}
-
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
 	if !ok {
-		t.Errorf("program.Statements[0] is not ast.ExpressionStatement. got = %T",
-			program.Statements[0])
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
 	}
 
-	function, ok := stmt.Expression.(*ast.FunctionLiteral)
-	if !ok {
-		t.Errorf("stmt.Expression is not FunctionLiteral. got = %T",
-			stmt.Expression)
+	conditions := []string{}
+	for chained := exp; chained != nil; chained = chained.ElseIf() {
+		conditions = append(conditions, chained.Condition.String())
+		exp = chained
 	}
 
-	if len(function.Parameters) != 2 {
-		t.Errorf("function literal parameters wrong. want 2, got = %d\n",
-			len(function.Parameters))
+	if strings.Join(conditions, ", ") != "a, b, c" {
+		t.Errorf("wrong chain of conditions. got=%q", conditions)
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
-
-	if len(function.Body.Statements) != 1 {
-		t.Errorf("function.Body.Statements has not 1 statements. got = %d\n",
-			len(function.Body.Statements))
+	if exp.Alternative == nil || exp.Alternative.String() != "4" {
+		t.Errorf("last alternative is not 4. got=%+v", exp.Alternative)
 	}
 
-	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
-	if !ok {
-		t.Errorf("function body statement is not ast.ExpressionStatement. got = %T",
-			function.Body.Statements[0])
+	expected := "ifa 1else ifb 2else ifc 3else 4"
+	if program.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, program.String())
 	}
-
-	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
-func TestFunctionParametersParsing(t *testing.T) {
+func TestElseIfErrors(t *testing.T) {
 	tests := []struct {
-		input          string
-		expectedParams []string
+		input    string
+		expected string
 	}{
-		{"fn() {};", []string{}},
-		{"fn(x) {};", []string{"x"}},
-		{"fn(x,y,  z) {};", []string{"x", "y", "z"}},
+		{"if (a) { 1 } else if b { 2 }", "expected next token to be (, got IDENT"},
+		{"if (a) { 1 } else if (b) 2", "expected next token to be {, got INT"},
+		{"if (a) { 1 } else if (b) { 2 } else 3", "expected next token to be {, got INT"},
 	}
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
-		program := p.ParseProgram()
-		checkParserErrors(t, p)
-
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
-		function := stmt.Expression.(*ast.FunctionLiteral)
+		p.ParseProgram()
 
-		if len(function.Parameters) != len(tt.expectedParams) {
-			t.Errorf("length parameters wrong. want %d, got = %d\n",
-				len(tt.expectedParams), len(function.Parameters))
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("no errors for %q", tt.input)
+			continue
 		}
 
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
 		}
 	}
 }
 
-func TestCallExpression(t *testing.T) {
-	input := `add(1, 2* 3, 4 + 5);`
+func TestElifExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (a) { 1 } elif (b) { 2 }", "if (a) { 1 } else if (b) { 2 }"},
+		{"if (a) { 1 } elif (b) { 2 } elif (c) { 3 } else { 4 }", "if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }"},
+		{"if (a) { 1 } elif (b) { 2 } else if (c) { 3 } else { 4 }", "if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }"},
+		{"if (a) { 1 } else if (b) { 2 } elif (c) { 3 }", "if (a) { 1 } else if (b) { 2 } else if (c) { 3 }"},
+	}
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	if len(program.Statements) != 1 {
-		t.Errorf("program.Statements does not contain %d statements. got = %d",
-			1, len(program.Statements))
-	}
+		expected := New(lexer.New(tt.expected)).ParseProgram()
+		if program.String() != expected.String() {
+			t.Errorf("%q parsed differently from %q. got=%q, want=%q",
+				tt.input, tt.expected, program.String(), expected.String())
+		}
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
-	if !ok {
-		t.Errorf("stmt is not ast.ExpressionStatements. got = %T",
-			program.Statements[0])
+		exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+		if exp.ElseIf() == nil {
+			t.Errorf("elif in %q not parsed as an else-if chain", tt.input)
+		}
 	}
 
-	exp, ok := stmt.Expression.(*ast.CallExpression)
-	if !ok {
-		t.Errorf("exp is not ast.CallExpression. got = %T", stmt.Expression)
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"elif (a) { 1 }", "no prefix parse function for ELIF found"},
+		{"if (a) { 1 } elif b { 2 }", "expected next token to be (, got IDENT"},
+		{"if (a) { 1 } elif (b) 2", "expected next token to be {, got INT"},
+		{"if (a) { 1 } else elif (b) { 2 }", "expected next token to be {, got ELIF"},
 	}
 
-	if !testIdentifier(t, exp.Function, "add") {
-		return
-	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
 
-	if len(exp.Arguments) != 3 {
-		t.Fatalf("wrong length of arguments. want 3, got = %d", len(exp.Arguments))
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
 	}
-
-	testLiteralExpression(t, exp.Arguments[0], 1)
-	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
-	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
-func TestCallExpressionParsing(t *testing.T) {
-	input := "add(1, 2 * 3, 4 + 5);"
+func TestElseWithNestedIfIsNotAChain(t *testing.T) {
+	input := `if (a) { 1 } else { if (b) { 2 } }`
 
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	if len(program.Statements) != 1 {
-		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
-			1, len(program.Statements))
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if exp.ElseIf() != nil {
+		t.Errorf("else block parsed as an else-if chain")
 	}
+}
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
-	if !ok {
-		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T",
-			program.Statements[0])
+func TestYieldExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"yield 1", "yield 1"},
+		{"yield x + 1;", "yield (x + 1)"},
+		{"let x = yield f(1)", "let x = yield f(1);"},
+		{"fn() { yield 1; yield 2 }", "fn() yield 1yield 2"},
 	}
 
-	exp, ok := stmt.Expression.(*ast.CallExpression)
-	if !ok {
-		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T",
-			stmt.Expression)
-	}
-
-	if !testIdentifier(t, exp.Function, "add") {
-		return
-	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	if len(exp.Arguments) != 3 {
-		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
 	}
-
-	testLiteralExpression(t, exp.Arguments[0], 1)
-	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
-	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
-func TestCallExpressionParameterParsing(t *testing.T) {
+func TestWithExpressionParsing(t *testing.T) {
 	tests := []struct {
-		input         string
-		expectedIdent string
-		expectedArgs  []string
+		input    string
+		expected string
 	}{
-		{
-			input:         "add();",
-			expectedIdent: "add",
-			expectedArgs:  []string{},
-		},
-		{
-			input:         "add(1);",
-			expectedIdent: "add",
-			expectedArgs:  []string{"1"},
-		},
-		{
-			input:         "add(1, 2 * 3, 4 + 5);",
-			expectedIdent: "add",
-			expectedArgs:  []string{"1", "(2 * 3)", "(4 + 5)"},
-		},
+		{"with (x = 5, y = 10) { x + y }", "with (x = 5, y = 10) (x + y)"},
+		{"with (x = 5,) { x }", "with (x = 5) x"},
+		{"let z = with (x = f(1)) { let y = x; y * 2 };", "let z = with (x = f(1)) let y = x;(y * 2);"},
+		{"with (x = 1) { } + 1", "(with (x = 1)  + 1)"},
 	}
 
 	for _, tt := range tests {
@@ -690,208 +952,303 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
 
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
-		exp, ok := stmt.Expression.(*ast.CallExpression)
-		if !ok {
-			t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T",
-				stmt.Expression)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
 		}
+	}
 
-		if !testIdentifier(t, exp.Function, tt.expectedIdent) {
-			return
-		}
+	program := New(lexer.New("with (f = fn() { 1 }) { f() }")).ParseProgram()
+	with := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.WithExpression)
+	if fl := with.Values[0].(*ast.FunctionLiteral); fl.Name != "f" {
+		t.Errorf("function bound by with not named. got=%q", fl.Name)
+	}
 
-		if len(exp.Arguments) != len(tt.expectedArgs) {
-			t.Fatalf("wrong number of arguments. want=%d, got=%d",
-				len(tt.expectedArgs), len(exp.Arguments))
-		}
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"with () { 1 }", "with needs at least one binding"},
+		{"with x = 1 { x }", "expected next token to be (, got IDENT"},
+		{"with (x 1) { x }", "expected next token to be =, got INT"},
+		{"with (x = 1 y = 2) { x }", "expected next token to be ,, got IDENT"},
+		{"with (x = 1) x", "expected next token to be {, got IDENT"},
+		{"with (x = 1", "unterminated binding list started at line 1: expected ')' before EOF"},
+	}
 
-		for i, arg := range tt.expectedArgs {
-			if exp.Arguments[i].String() != arg {
-				t.Errorf("argument %d wrong. want=%q, got=%q", i,
-					arg, exp.Arguments[i].String())
-			}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
 		}
 	}
 }
 
-func TestStringLiteralExpression(t *testing.T) {
-	input := `"hello world";`
-
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if len(program.Statements) != 1 {
+		t.Errorf("program.Statements does not contain %d statements. got = %d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
 	if !ok {
-		t.Fatalf("exp not *ast.StringLiteral. got = %T", stmt.Expression)
+		t.Errorf("program.Statements[0] is not ast.ExpressionStatement. got = %T",
+			program.Statements[0])
 	}
 
-	if literal.Value != "hello world" {
-		t.Errorf("literal.Value not %q. got = %q", "hello world", literal.Value)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Errorf("stmt.Expression is not FunctionLiteral. got = %T",
+			stmt.Expression)
 	}
 
-}
+	if len(function.Parameters) != 2 {
+		t.Errorf("function literal parameters wrong. want 2, got = %d\n",
+			len(function.Parameters))
+	}
 
-func TestParsingEmptyArrayLiterals(t *testing.T) {
-	input := "[]"
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
+	if len(function.Body.Statements) != 1 {
+		t.Errorf("function.Body.Statements has not 1 statements. got = %d\n",
+			len(function.Body.Statements))
+	}
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
-	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
 	if !ok {
-		t.Fatalf("exp not ast.Literal. got = %T", stmt.Expression)
+		t.Errorf("function body statement is not ast.ExpressionStatement. got = %T",
+			function.Body.Statements[0])
 	}
 
-	if len(array.Elements) != 0 {
-		t.Errorf("len(array.Elements) not 0. got = %d", len(array.Elements))
-	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
-func TestParsingArrayLiterals(t *testing.T) {
-	input := "[1, 2*2, 3 + 3]"
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
 
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
-	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
 	if !ok {
-		t.Fatalf("exp not ast.ArrayLiteral. got = %T", stmt.Expression)
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+			program.Statements[0])
 	}
 
-	if len(array.Elements) != 3 {
-		t.Errorf("len(array.Elements) not 3. got = %d", len(array.Elements))
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
 	}
 
-	testIntegerLiteral(t, array.Elements[0], 1)
-	testInfixExpression(t, array.Elements[1], 2, "*", 2)
-	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+	if function.Name != "myFunction" {
+		t.Fatalf("function literal name wrong. want 'myFunction', got=%q",
+			function.Name)
+	}
 }
 
-func TestParsingIndexExpressions(t *testing.T) {
-	input := "myArray[1 + 1]"
-
-	l := lexer.New(input)
-	p := New(l)
+func TestBlankIdentifier(t *testing.T) {
+	p := New(lexer.New(`let _ = fn(_, x, _) { x }; let _ = 2;`))
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
-	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
-	if !ok {
-		t.Fatalf("exp not ast.IndexExpression. got = %T", stmt.Expression)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
 	}
 
-	if !testIdentifier(t, indexExp.Left, "myArray") {
-		return
+	stmt := program.Statements[0].(*ast.LetStatement)
+	if !stmt.Name.IsBlank() {
+		t.Errorf("let name is not blank. got=%q", stmt.Name.Value)
+	}
+	function := stmt.Value.(*ast.FunctionLiteral)
+	if function.Name != "" {
+		t.Errorf("a function bound to _ shouldn't be named. got=%q", function.Name)
+	}
+	for i, blank := range []bool{true, false, true} {
+		if function.Parameters[i].IsBlank() != blank {
+			t.Errorf("parameter %d IsBlank() not %t", i, blank)
+		}
 	}
 
-	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
-		return
+	for _, input := range []string{"_", "let x = _ + 1;", "f(_)", "{_: 1}", "fn(_) { _ }"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		expected := "_ can only be bound, not used as a value"
+		if len(errors) != 1 || errors[0] != expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", input, expected, errors)
+		}
+	}
+
+	p = New(lexer.New("enum _ { A }"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != "_ can't name an enum" {
+		t.Errorf("wrong errors for a blank enum name. got=%q", errors)
 	}
 }
 
-func TestParsingEmptyHashLiteral(t *testing.T) {
-	input := "{}"
+func TestDoExpressionParsing(t *testing.T) {
+	input := `do { let t = 1; t * 2 }`
 
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
 	if !ok {
-		t.Errorf("exp not ast.HashLiteral. got = %T", stmt.Expression)
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
 	}
 
-	if len(hash.Pairs) != 0 {
-		t.Errorf("hash.Pairs has wrong length. got = %d", len(hash.Pairs))
+	exp, ok := stmt.Expression.(*ast.DoExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.DoExpression. got=%T", stmt.Expression)
 	}
-}
 
-func TestParsingHashLiteralsStringKeys(t *testing.T) {
-	input := `{"one": 1, "two": 2, "three": 3}`
+	if len(exp.Body.Statements) != 2 {
+		t.Fatalf("do body has wrong number of statements. got=%d", len(exp.Body.Statements))
+	}
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
+	if _, ok := exp.Body.Statements[0].(*ast.LetStatement); !ok {
+		t.Fatalf("exp.Body.Statements[0] is not ast.LetStatement. got=%T", exp.Body.Statements[0])
+	}
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
-	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	last, ok := exp.Body.Statements[1].(*ast.ExpressionStatement)
 	if !ok {
-		t.Fatalf("exp not ast.HashLiteral. got = %T", stmt.Expression)
+		t.Fatalf("exp.Body.Statements[1] is not ast.ExpressionStatement. got=%T", exp.Body.Statements[1])
 	}
 
-	if len(hash.Pairs) != 3 {
-		t.Errorf("hash.Pairs has wrong length. got = %d", len(hash.Pairs))
-	}
+	testInfixExpression(t, last.Expression, "t", "*", 2)
+}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
+func TestFunctionParametersParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{"fn() {};", []string{}},
+		{"fn(x) {};", []string{"x"}},
+		{"fn(x,y,  z) {};", []string{"x", "y", "z"}},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
-		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got = %T", key)
-		}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-		expectedValue := expected[literal.String()]
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
 
-		testIntegerLiteral(t, value, expectedValue)
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got = %d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
 	}
 }
 
-func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
-	input := `{true: 1, false: 2}`
+func TestCallExpression(t *testing.T) {
+	input := `add(1, 2* 3, 4 + 5);`
 
 	l := lexer.New(input)
 	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	hash, ok := stmt.Expression.(*ast.HashLiteral)
-	if !ok {
-		t.Fatalf("exp not ast.HashLiteral. got = %T", stmt.Expression)
+	if len(program.Statements) != 1 {
+		t.Errorf("program.Statements does not contain %d statements. got = %d",
+			1, len(program.Statements))
 	}
 
-	expected := map[string]int64{
-		"true":  1,
-		"false": 2,
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Errorf("stmt is not ast.ExpressionStatements. got = %T",
+			program.Statements[0])
 	}
 
-	if len(hash.Pairs) != 2 {
-		t.Errorf("hash.Pairs has wrong length. got = %d", len(hash.Pairs))
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Errorf("exp is not ast.CallExpression. got = %T", stmt.Expression)
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.Boolean)
-		if !ok {
-			t.Errorf("key is not ast.Boolean. got = %T", key)
-		}
-
-		expectedValue := expected[literal.String()]
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
 
-		testIntegerLiteral(t, value, expectedValue)
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. want 3, got = %d", len(exp.Arguments))
 	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
-func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
-	input := `{1: 1, 2: 2, 3: 3}`
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestIndexThenCallParsing(t *testing.T) {
+	input := `dispatch["add"](1, 2)`
 
 	l := lexer.New(input)
 	p := New(l)
@@ -899,36 +1256,237 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 	checkParserErrors(t, p)
 
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	call, ok := stmt.Expression.(*ast.CallExpression)
 	if !ok {
-		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
 	}
 
-	expected := map[string]int64{
-		"1": 1,
-		"2": 2,
-		"3": 3,
+	index, ok := call.Function.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("call.Function is not ast.IndexExpression. got=%T", call.Function)
+	}
+	if !testIdentifier(t, index.Left, "dispatch") {
+		return
+	}
+	key, ok := index.Index.(*ast.StringLiteral)
+	if !ok || key.Value != "add" {
+		t.Fatalf("index.Index is not the string \"add\". got=%T (%s)", index.Index, index.Index)
 	}
 
-	if len(hash.Pairs) != len(expected) {
-		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	if len(call.Arguments) != 2 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
 	}
+	testLiteralExpression(t, call.Arguments[0], 1)
+	testLiteralExpression(t, call.Arguments[1], 2)
+}
 
-	for key, value := range hash.Pairs {
-		integer, ok := key.(*ast.IntegerLiteral)
+func TestImmediatelyInvokedFunctionParsing(t *testing.T) {
+	tests := []string{
+		"fn(x) { x * 2 }(21)",
+		"(fn(x) { x * 2 })(21)",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement for %q. got=%d",
+				input, len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		call, ok := stmt.Expression.(*ast.CallExpression)
 		if !ok {
-			t.Errorf("key is not ast.IntegerLiteral. got=%T", key)
-			continue
+			t.Fatalf("stmt.Expression is not ast.CallExpression for %q. got=%T", input, stmt.Expression)
 		}
 
-		expectedValue := expected[integer.String()]
+		function, ok := call.Function.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("call.Function is not ast.FunctionLiteral for %q. got=%T", input, call.Function)
+		}
+		if len(function.Parameters) != 1 {
+			t.Fatalf("function literal has wrong parameters for %q. got=%d", input, len(function.Parameters))
+		}
 
-		testIntegerLiteral(t, value, expectedValue)
+		if len(call.Arguments) != 1 {
+			t.Fatalf("wrong length of arguments for %q. got=%d", input, len(call.Arguments))
+		}
+		testLiteralExpression(t, call.Arguments[0], 21)
 	}
 }
 
-func TestParsingHashLiteralsWithExpressions(t *testing.T) {
-	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
+func TestCallExpressionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedIdent string
+		expectedArgs  []string
+	}{
+		{
+			input:         "add();",
+			expectedIdent: "add",
+			expectedArgs:  []string{},
+		},
+		{
+			input:         "add(1);",
+			expectedIdent: "add",
+			expectedArgs:  []string{"1"},
+		},
+		{
+			input:         "add(1, 2 * 3, 4 + 5);",
+			expectedIdent: "add",
+			expectedArgs:  []string{"1", "(2 * 3)", "(4 + 5)"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if !testIdentifier(t, exp.Function, tt.expectedIdent) {
+			return
+		}
+
+		if len(exp.Arguments) != len(tt.expectedArgs) {
+			t.Fatalf("wrong number of arguments. want=%d, got=%d",
+				len(tt.expectedArgs), len(exp.Arguments))
+		}
+
+		for i, arg := range tt.expectedArgs {
+			if exp.Arguments[i].String() != arg {
+				t.Errorf("argument %d wrong. want=%q, got=%q", i,
+					arg, exp.Arguments[i].String())
+			}
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got = %T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got = %q", "hello world", literal.Value)
+	}
+
+}
+
+func TestParsingEmptyArrayLiterals(t *testing.T) {
+	input := "[]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.Literal. got = %T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got = %d", len(array.Elements))
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2*2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got = %T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Errorf("len(array.Elements) not 3. got = %d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not ast.IndexExpression. got = %T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[1:2:3]", "(a[1:2:3])"},
+		{"a[:]", "(a[:])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[::-1]", "(a[::(-1)])"},
+		{"a[::]", "(a[:])"},
+		{"a[1 + 1:len(a)]", "(a[(1 + 1):len(a)])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
 
 	l := lexer.New(input)
 	p := New(l)
@@ -938,76 +1496,952 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
 	hash, ok := stmt.Expression.(*ast.HashLiteral)
 	if !ok {
-		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+		t.Errorf("exp not ast.HashLiteral. got = %T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got = %d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsStringKeys(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.HashLiteral. got = %T", stmt.Expression)
 	}
 
 	if len(hash.Pairs) != 3 {
-		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+		t.Errorf("hash.Pairs has wrong length. got = %d", len(hash.Pairs))
 	}
 
-	tests := map[string]func(ast.Expression){
-		"one": func(e ast.Expression) {
-			testInfixExpression(t, e, 0, "+", 1)
+	expected := map[string]int64{
+		"one":   1,
+		"two":   2,
+		"three": 3,
+	}
+
+	for key, value := range hash.Pairs {
+		literal, ok := key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got = %T", key)
+		}
+
+		expectedValue := expected[literal.String()]
+
+		testIntegerLiteral(t, value, expectedValue)
+	}
+}
+
+func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
+	input := `{true: 1, false: 2}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.HashLiteral. got = %T", stmt.Expression)
+	}
+
+	expected := map[string]int64{
+		"true":  1,
+		"false": 2,
+	}
+
+	if len(hash.Pairs) != 2 {
+		t.Errorf("hash.Pairs has wrong length. got = %d", len(hash.Pairs))
+	}
+
+	for key, value := range hash.Pairs {
+		literal, ok := key.(*ast.Boolean)
+		if !ok {
+			t.Errorf("key is not ast.Boolean. got = %T", key)
+		}
+
+		expectedValue := expected[literal.String()]
+
+		testIntegerLiteral(t, value, expectedValue)
+	}
+}
+
+func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
+	input := `{1: 1, 2: 2, 3: 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := map[string]int64{
+		"1": 1,
+		"2": 2,
+		"3": 3,
+	}
+
+	if len(hash.Pairs) != len(expected) {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	for key, value := range hash.Pairs {
+		integer, ok := key.(*ast.IntegerLiteral)
+		if !ok {
+			t.Errorf("key is not ast.IntegerLiteral. got=%T", key)
+			continue
+		}
+
+		expectedValue := expected[integer.String()]
+
+		testIntegerLiteral(t, value, expectedValue)
+	}
+}
+
+func TestParsingHashLiteralsMixedKeys(t *testing.T) {
+	input := `{1: "a", true: "b", "k": "c", 1: "d"}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.HashLiteral. got = %T", stmt.Expression)
+	}
+
+	if len(hash.Keys) != 4 || len(hash.Pairs) != 4 {
+		t.Fatalf("hash has wrong number of keys. got = %d keys, %d pairs",
+			len(hash.Keys), len(hash.Pairs))
+	}
+
+	testIntegerLiteral(t, hash.Keys[0], 1)
+	testBooleanLiteral(t, hash.Keys[1], true)
+	if key, ok := hash.Keys[2].(*ast.StringLiteral); !ok || key.Value != "k" {
+		t.Errorf("key is not ast.StringLiteral k. got = %T (%s)", hash.Keys[2], hash.Keys[2])
+	}
+	testIntegerLiteral(t, hash.Keys[3], 1)
+
+	expected := "{1:a, true:b, k:c, 1:d}"
+	if hash.String() != expected {
+		t.Errorf("expected = %q, got = %q", expected, hash.String())
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	tests := map[string]func(ast.Expression){
+		"one": func(e ast.Expression) {
+			testInfixExpression(t, e, 0, "+", 1)
+		},
+		"two": func(e ast.Expression) {
+			testInfixExpression(t, e, 10, "-", 8)
+		},
+		"three": func(e ast.Expression) {
+			testInfixExpression(t, e, 15, "/", 5)
+		},
+	}
+
+	for key, value := range hash.Pairs {
+		literal, ok := key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			continue
+		}
+
+		testFunc, ok := tests[literal.String()]
+		if !ok {
+			t.Errorf("No test function for key %q found", literal.String())
+			continue
+		}
+
+		testFunc(value)
+	}
+}
+
+func TestLogicalOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"true && false",
+			"(true && false)",
+		},
+		{
+			"true || false",
+			"(true || false)",
+		},
+		{
+			"true && false || true",
+			"((true && false) || true)",
+		},
+		{
+			"true || false && true",
+			"(true || (false && true))",
+		},
+
+		{
+			"3 > 5 && 10 < 20",
+			"((3 > 5) && (10 < 20))",
+		},
+		{
+			"true && (false || true)",
+			"(true && (false || true))",
+		},
+		{
+			"!true && false",
+			"((!true) && false)",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func checkParserErrors(t *testing.T, p *Parser) {
+	errors := p.errors
+	if len(errors) == 0 {
+		return
+	}
+
+	t.Errorf("parser has %d errors", len(errors))
+	for _, msg := range errors {
+		t.Errorf("parser error: %q", msg)
+	}
+	t.FailNow()
+}
+
+func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
+	if s.TokenLiteral() != "let" {
+		t.Errorf("s.TokenLiteral not 'let'. got = %q", s.TokenLiteral())
+		return false
+	}
+
+	letStmt, ok := s.(*ast.LetStatement)
+	if !ok {
+		t.Errorf("s not *ast.LetStatement. got = %T", s)
+		return false
+	}
+
+	if letStmt.Name.TokenLiteral() != name {
+		t.Errorf("letStmt.Name.TokenLiteral() not %s. got %s",
+			name, letStmt.Name.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
+	integ, ok := il.(*ast.IntegerLiteral)
+	if !ok {
+		t.Errorf("il not *ast.IntegerLiteral. got = %T", il)
+		return false
+	}
+
+	if integ.Value != value {
+		t.Errorf("integ.Value not %d. got = %d", integ.Value, value)
+		return false
+	}
+
+	if integ.TokenLiteral() != fmt.Sprintf("%d", value) {
+		t.Errorf("integ.TokenLiteral() not %d. got = %s", value, integ.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Errorf("exp not *ast.Identifier. got = %T", exp)
+		return false
+	}
+
+	if ident.Value != value {
+		t.Errorf("ident.Value not %s. got = %s", value, ident.Value)
+		return false
+	}
+
+	if ident.TokenLiteral() != value {
+		t.Errorf("ident.TokenLiteral not %s. got = %s", value, ident.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func testLiteralExpression(
+	t *testing.T,
+	exp ast.Expression,
+	expected interface{},
+) bool {
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		return testIntegerLiteral(t, exp, v)
+	case string:
+		return testIdentifier(t, exp, v)
+	case bool:
+		return testBooleanLiteral(t, exp, v)
+	}
+	t.Errorf("type of exp not handled. got = %T", exp)
+	return false
+}
+
+func testInfixExpression(t *testing.T, exp ast.Expression, left interface{},
+	operator string, right interface{}) bool {
+	opExp, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Errorf("exp is not ast.InfixExpression. got = %T(%s)", exp, exp)
+		return false
+	}
+
+	if !testLiteralExpression(t, opExp.Left, left) {
+		return false
+	}
+
+	if opExp.Operator != operator {
+		t.Errorf("exp.Operator is not '%s'. got = %s", operator, opExp.Operator)
+		return false
+	}
+
+	if !testLiteralExpression(t, opExp.Right, right) {
+		return false
+	}
+
+	return true
+}
+
+func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
+	bo, ok := exp.(*ast.Boolean)
+	if !ok {
+		t.Errorf("exp is not *ast.Boolean. got = %T", exp)
+		return false
+	}
+
+	if bo.Value != value {
+		t.Errorf("bo.Value is not %t. got = %t", value, bo.Value)
+		return false
+	}
+
+	if bo.TokenLiteral() != fmt.Sprintf("%t", value) {
+		t.Errorf("bo.TokenLiteral() not %t. got = %s",
+			value, bo.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func TestPipeOperatorPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"x |> f",
+			"(x |> f)",
+		},
+		{
+			"x |> f |> g",
+			"((x |> f) |> g)",
+		},
+		{
+			"x |> f(1, 2)",
+			"(x |> f(1, 2))",
+		},
+		{
+			"a + b |> f",
+			"((a + b) |> f)",
+		},
+		{
+			"a || b |> f",
+			"((a || b) |> f)",
+		},
+		{
+			"x |> f |> g(y |> h)",
+			"((x |> f) |> g((y |> h)))",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestMethodCallExpressionParsing(t *testing.T) {
+	input := `myArray.push(1, 2 * 3)`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MethodCallExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.MethodCallExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Receiver, "myArray") {
+		return
+	}
+
+	if !testIdentifier(t, exp.Method, "push") {
+		return
+	}
+
+	if len(exp.Arguments) != 2 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+}
+
+func TestMethodCallPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"a.len()",
+			"a.len()",
+		},
+		{
+			"a.push(1).len()",
+			"a.push(1).len()",
+		},
+		{
+			"-a.len()",
+			"(-a.len())",
+		},
+		{
+			"a.len() + b.len() * 2",
+			"(a.len() + (b.len() * 2))",
+		},
+		{
+			"a[0].len()",
+			"(a[0]).len()",
+		},
+		{
+			"[1, 2].first()",
+			"[1, 2].first()",
+		},
+		{
+			`"hi".len()`,
+			"hi.len()",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestEnumStatements(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedName    string
+		expectedMembers []string
+	}{
+		{"enum Color { Red, Green, Blue }", "Color", []string{"Red", "Green", "Blue"}},
+		{"enum Color { Red, Green, Blue, };", "Color", []string{"Red", "Green", "Blue"}},
+		{"enum Unit { Only }", "Unit", []string{"Only"}},
+		{"enum Empty {}", "Empty", []string{}},
+		{"enum State {\n\tIdle,\n\tRunning\n}", "State", []string{"Idle", "Running"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement for %q. got=%d",
+				tt.input, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.EnumStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.EnumStatement. got=%T", program.Statements[0])
+		}
+		if !testIdentifier(t, stmt.Name, tt.expectedName) {
+			return
+		}
+		if len(stmt.Members) != len(tt.expectedMembers) {
+			t.Fatalf("wrong number of members for %q. got=%d", tt.input, len(stmt.Members))
+		}
+		for i, member := range tt.expectedMembers {
+			testIdentifier(t, stmt.Members[i], member)
+		}
+	}
+
+	p := New(lexer.New("enum Color { Red, Green }; Color.Red"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "enum Color { Red, Green }Color.Red" {
+		t.Errorf("wrong program string. got=%q", program.String())
+	}
+
+	member, ok := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.MemberExpression. got=%T", program.Statements[1])
+	}
+	testIdentifier(t, member.Object, "Color")
+	testIdentifier(t, member.Member, "Red")
+}
+
+func TestEnumStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum { Red }", "expected next token to be IDENT, got {"},
+		{"enum Color Red", "expected next token to be {, got IDENT"},
+		{"enum Color { Red Green }", "expected next token to be ,, got IDENT"},
+		{"enum Color { 1 }", "expected next token to be IDENT, got INT"},
+		{"enum Color { Red, Red }", "duplicate member Red in enum Color"},
+		{"enum Color { Red,", "unterminated enum started at line 1: expected '}' before EOF"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("wrong number of errors for %q. got=%q", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestMethodCallParsingErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a.1()", "expected next token to be IDENT, got INT"},
+		{"a.", "expected next token to be IDENT, got EOF"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+// TestPrecedenceAndAssociativity pins down how prefix operators bind against the infix ones and
+// that every binary operator is left-associative
+func TestPrecedenceAndAssociativity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// prefix operators bind tighter than any binary operator
+		{"-a * b", "((-a) * b)"},
+		{"!a == b", "((!a) == b)"},
+		{"-a - -b", "((-a) - (-b))"},
+		{"a * -b", "(a * (-b))"},
+		{"a < -b", "(a < (-b))"},
+		{"a == !b", "(a == (!b))"},
+		{"a && !b", "(a && (!b))"},
+		{"-a |> f", "((-a) |> f)"},
+		{"!a && b || c", "(((!a) && b) || c)"},
+		// and looser than calls, indexing and method calls
+		{"-a[0]", "(-(a[0]))"},
+		{"-f(x)", "(-f(x))"},
+		{"-a.m()", "(-a.m())"},
+		{"!f(x)[0]", "(!(f(x)[0]))"},
+		// prefix operators nest
+		{"!-a", "(!(-a))"},
+		{"- -a", "(-(-a))"},
+		{"!!a", "(!(!a))"},
+		// binary operators of the same precedence associate to the left
+		{"a - b - c", "((a - b) - c)"},
+		{"a / b / c", "((a / b) / c)"},
+		{"a - b + c", "((a - b) + c)"},
+		{"a / b * c", "((a / b) * c)"},
+		{"a == b != c", "((a == b) != c)"},
+		{"a && b && c", "((a && b) && c)"},
+		{"a || b || c", "((a || b) || c)"},
+		{"a |> f |> g", "((a |> f) |> g)"},
+		// one operator of each level, from loosest to tightest
+		{"a |> f || b && c == d < e + g * -h", "(a |> (f || (b && (c == (d < (e + (g * (-h))))))))"},
+		{"-a * b + c < d == e && f || g |> h", "((((((((-a) * b) + c) < d) == e) && f) || g) |> h)"},
+		{"a + b * c < d", "((a + (b * c)) < d)"},
+		{"a < b == c > d", "((a < b) == (c > d))"},
+		{"a || b |> f", "((a || b) |> f)"},
+		// postfix operators chain to the left
+		{"f(a)(b)", "f(a)(b)"},
+		{"a[0][1]", "((a[0])[1])"},
+		{"a[0](1)", "(a[0])(1)"},
+		{"a.m().n()", "a.m().n()"},
+		{"a + b(c * d)[e]", "(a + (b((c * d))[e]))"},
+		// grouping overrides all of the above
+		{"-(a + b)", "(-(a + b))"},
+		{"!(a == b)", "(!(a == b))"},
+		{"a - (b - c)", "(a - (b - c))"},
+		{"(a |> f) + 1", "((a |> f) + 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("wrong parse of %q. expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		tokenType token.TokenType
+		expected  int
+	}{
+		{token.PIPE, PIPE},
+		{token.OR, LOGICAL_OR},
+		{token.AND, LOGICAL_AND},
+		{token.EQ, EQUALS},
+		{token.NOT_EQ, EQUALS},
+		{token.LT, LESSGREATER},
+		{token.GT, LESSGREATER},
+		{token.PLUS, SUM},
+		{token.MINUS, SUM},
+		{token.ASTERISK, PRODUCT},
+		{token.PERCENT, PRODUCT},
+		{token.SLASH, PRODUCT},
+		{token.LPAREN, CALL},
+		{token.LBRACKET, INDEX},
+		{token.DOT, INDEX},
+		{token.BANG, LOWEST},
+		{token.IDENT, LOWEST},
+		{token.SEMICOLON, LOWEST},
+	}
+
+	for _, tt := range tests {
+		if got := Precedence(tt.tokenType); got != tt.expected {
+			t.Errorf("wrong precedence for %s. expected=%d, got=%d",
+				tt.tokenType, tt.expected, got)
+		}
+	}
+}
+
+func TestRegisteredParseFunctions(t *testing.T) {
+	p := New(lexer.New(""))
+
+	prefix := p.PrefixTokens()
+	infix := p.InfixTokens()
+
+	for _, tokens := range [][]token.TokenType{prefix, infix} {
+		for i := 1; i < len(tokens); i++ {
+			if tokens[i-1] >= tokens[i] {
+				t.Errorf("tokens not sorted: %q before %q", tokens[i-1], tokens[i])
+			}
+		}
+	}
+
+	if !containsTokenType(prefix, token.MINUS) || !containsTokenType(prefix, token.IDENT) {
+		t.Errorf("prefix tokens missing MINUS or IDENT. got=%v", prefix)
+	}
+
+	if containsTokenType(prefix, token.ASTERISK) {
+		t.Errorf("prefix tokens unexpectedly contain ASTERISK. got=%v", prefix)
+	}
+
+	for _, tokenType := range infix {
+		if Precedence(tokenType) == LOWEST {
+			t.Errorf("infix token %s has no precedence", tokenType)
+		}
+	}
+
+	for tokenType := range precedences {
+		if !containsTokenType(infix, tokenType) {
+			t.Errorf("token %s has a precedence but no infix parse function", tokenType)
+		}
+	}
+}
+
+func containsTokenType(tokens []token.TokenType, t token.TokenType) bool {
+	for _, tok := range tokens {
+		if tok == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements string
+	}{
+		{
+			"let = 5; let y = 10;",
+			[]string{"expected next token to be IDENT, got ="},
+			"let y = 10;",
 		},
-		"two": func(e ast.Expression) {
-			testInfixExpression(t, e, 10, "-", 8)
+		{
+			"let x 5; let = 10; let z = 15;",
+			[]string{
+				"expected next token to be =, got INT",
+				"expected next token to be IDENT, got =",
+			},
+			"let z = 15;",
 		},
-		"three": func(e ast.Expression) {
-			testInfixExpression(t, e, 15, "/", 5)
+		{
+			// a single error shouldn't cascade into the rest of the call
+			"add(1, , 2, 3); x;",
+			[]string{"no prefix parse function for , found"},
+			"x",
+		},
+		{
+			"let f = fn(x) { let = x; x + 1 }; f(1);",
+			[]string{"expected next token to be IDENT, got ="},
+			"let f = fn(x) (x + 1);f(1)",
+		},
+		{
+			"let f = fn(x) { x + }; let y = 2;",
+			[]string{"no prefix parse function for } found"},
+			"let f = fn(x) ;let y = 2;",
+		},
+		{
+			"let f = fn() { if (x { 1 } }; let y = 1;",
+			[]string{"expected next token to be ), got {"},
+			"let f = fn() ;let y = 1;",
+		},
+		{
+			`let h = {"a" 1, "b": {"c": 2}}; let y = 2;`,
+			[]string{"expected next token to be :, got INT"},
+			"let y = 2;",
+		},
+		{
+			"if (x { 1 }; let a = 1; if (y) { 2 } else 3; let b = 2;",
+			[]string{
+				"expected next token to be ), got {",
+				"expected next token to be {, got INT",
+			},
+			"let a = 1;let b = 2;",
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
-		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q",
+				tt.input, tt.expectedErrors, errors)
 			continue
 		}
 
-		testFunc, ok := tests[literal.String()]
-		if !ok {
-			t.Errorf("No test function for key %q found", literal.String())
-			continue
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("wrong error %d for %q. expected=%q, got=%q",
+					i, tt.input, msg, errors[i])
+			}
 		}
 
-		testFunc(value)
+		if program.String() != tt.expectedStatements {
+			t.Errorf("wrong statements after recovery for %q. expected=%q, got=%q",
+				tt.input, tt.expectedStatements, program.String())
+		}
 	}
 }
 
-func TestLogicalOperatorPrecedence(t *testing.T) {
+func TestUnterminatedConstructs(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected string
+		expected []string
 	}{
 		{
-			"true && false",
-			"(true && false)",
+			"let f = fn(x) {\n  x + 1;\n",
+			[]string{"unterminated block started at line 1: expected '}' before EOF"},
 		},
 		{
-			"true || false",
-			"(true || false)",
+			"let a = 1;\nlet f = fn(x) {\n  if (x) {\n    x +",
+			[]string{"unterminated block started at line 3: expected '}' before EOF"},
 		},
 		{
-			"true && false || true",
-			"((true && false) || true)",
+			"let f = fn(x) {\n  let = 1;\n",
+			[]string{
+				"expected next token to be IDENT, got =",
+				"unterminated block started at line 1: expected '}' before EOF",
+			},
 		},
 		{
-			"true || false && true",
-			"(true || (false && true))",
+			"let a = [1, 2,\n3",
+			[]string{"unterminated array started at line 1: expected ']' before EOF"},
 		},
-
 		{
-			"3 > 5 && 10 < 20",
-			"((3 > 5) && (10 < 20))",
+			"let a = [1, 2,",
+			[]string{"unterminated array started at line 1: expected ']' before EOF"},
 		},
 		{
-			"true && (false || true)",
-			"(true && (false || true))",
+			"\n\nlet h = {\"a\": 1,\n\"b\": 2",
+			[]string{"unterminated hash started at line 3: expected '}' before EOF"},
 		},
 		{
-			"!true && false",
-			"((!true) && false)",
+			"let h = {\"a\":",
+			[]string{"unterminated hash started at line 1: expected '}' before EOF"},
+		},
+		{
+			"let x = (1 + 2",
+			[]string{"unterminated parenthesized expression started at line 1: expected ')' before EOF"},
+		},
+		{
+			"add(1,\n2",
+			[]string{"unterminated argument list started at line 1: expected ')' before EOF"},
+		},
+		{
+			"let f = fn(x, y",
+			[]string{"unterminated parameter list started at line 1: expected ')' before EOF"},
+		},
+		{
+			"a[1",
+			[]string{"unterminated index expression started at line 1: expected ']' before EOF"},
+		},
+		{
+			"if (x",
+			[]string{"unterminated if condition started at line 1: expected ')' before EOF"},
 		},
+		{
+			"[1, fn() { x",
+			[]string{"unterminated block started at line 1: expected '}' before EOF"},
+		},
+		{
+			// EOF outside of any construct keeps the generic message
+			"let x =",
+			[]string{"no prefix parse function for EOF found"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q",
+				tt.input, tt.expected, errors)
+			continue
+		}
+
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("wrong error %d for %q. expected=%q, got=%q",
+					i, tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3,]", "[1, 2, 3]"},
+		{"[1,]", "[1]"},
+		{"[\n  1,\n  2,\n]", "[1, 2]"},
+		{"[]", "[]"},
+		{"add(1, 2,)", "add(1, 2)"},
+		{"add(1,)", "add(1)"},
+		{"add()", "add()"},
+		{"a.push(1,)", "a.push(1)"},
+		{"fn(x, y,) { x }", "fn(x, y) x"},
+		{"fn(x,) { x }", "fn(x) x"},
+		{"fn() { 1 }", "fn() 1"},
+		{`{"a": 1,}`, `{a:1}`},
+		{"{}", "{}"},
 	}
 
 	for _, tt := range tests {
@@ -1023,140 +2457,235 @@ func TestLogicalOperatorPrecedence(t *testing.T) {
 	}
 }
 
-func checkParserErrors(t *testing.T, p *Parser) {
-	errors := p.errors
-	if len(errors) == 0 {
-		return
+func TestTrailingCommaErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[,]", "no prefix parse function for , found"},
+		{"[1,,]", "no prefix parse function for , found"},
+		{"add(,)", "no prefix parse function for , found"},
+		{`{"a": 1,,}`, "no prefix parse function for , found"},
+		{`{,}`, "no prefix parse function for , found"},
 	}
 
-	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
-	}
-	t.FailNow()
-}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
 
-func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
-	if s.TokenLiteral() != "let" {
-		t.Errorf("s.TokenLiteral not 'let'. got = %q", s.TokenLiteral())
-		return false
-	}
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("wrong number of errors for %q. got=%q", tt.input, errors)
+			continue
+		}
 
-	letStmt, ok := s.(*ast.LetStatement)
-	if !ok {
-		t.Errorf("s not *ast.LetStatement. got = %T", s)
-		return false
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
 	}
+}
 
-	if letStmt.Name.TokenLiteral() != name {
-		t.Errorf("letStmt.Name.TokenLiteral() not %s. got %s",
-			name, letStmt.Name.TokenLiteral())
-		return false
+func TestOptionalSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = 5\nlet y = 10\nx + y",
+			[]string{"let x = 5;", "let y = 10;", "(x + y)"},
+		},
+		{
+			"let f = fn(x) {\n  x\n}\n(5)",
+			[]string{"let f = fn(x) x;", "5"},
+		},
+		{
+			"let a = b\n[1, 2]",
+			[]string{"let a = b;", "[1, 2]"},
+		},
+		{
+			"let a = 5\n-3",
+			[]string{"let a = 5;", "(-3)"},
+		},
+		{
+			"return x\n-1",
+			[]string{"return x;", "(-1)"},
+		},
+		{
+			// operators that can't start an expression continue it on the next line
+			"let a = 1 +\n2\n* 3",
+			[]string{"let a = (1 + (2 * 3));"},
+		},
+		{
+			"x\n|> f\n|> g",
+			[]string{"((x |> f) |> g)"},
+		},
+		{
+			"[1, 2]\n.len()",
+			[]string{"[1, 2].len()"},
+		},
+		{
+			"add(1,\n2)\nadd(3,\n4)",
+			[]string{"add(1, 2)", "add(3, 4)"},
+		},
+		{
+			"let a = 1; let b = 2; a\nb",
+			[]string{"let a = 1;", "let b = 2;", "a", "b"},
+		},
+		{
+			"f(1)(2)\nf\n(1)",
+			[]string{"f(1)(2)", "f", "1"},
+		},
+		{
+			"a[0]\n[1]",
+			[]string{"(a[0])", "[1]"},
+		},
+		{
+			"if (x) { 1 }\nelse { 2 }",
+			[]string{"ifx 1else 2"},
+		},
 	}
 
-	return true
-}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
-	integ, ok := il.(*ast.IntegerLiteral)
-	if !ok {
-		t.Errorf("il not *ast.IntegerLiteral. got = %T", il)
-		return false
-	}
+		if len(program.Statements) != len(tt.expected) {
+			t.Errorf("wrong number of statements for %q. expected=%d, got=%d (%q)",
+				tt.input, len(tt.expected), len(program.Statements), program.String())
+			continue
+		}
 
-	if integ.Value != value {
-		t.Errorf("integ.Value not %d. got = %d", integ.Value, value)
-		return false
+		for i, expected := range tt.expected {
+			if program.Statements[i].String() != expected {
+				t.Errorf("wrong statement %d for %q. expected=%q, got=%q",
+					i, tt.input, expected, program.Statements[i].String())
+			}
+		}
 	}
+}
 
-	if integ.TokenLiteral() != fmt.Sprintf("%d", value) {
-		fmt.Errorf("integ.TokenLiteral() not %d. got = %s", value, integ.TokenLiteral())
-		return false
+func TestEmptyPrograms(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t\r\n  ",
+		"// just a comment",
+		"// one\n  // two\n",
 	}
 
-	return true
-}
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
-	ident, ok := exp.(*ast.Identifier)
-	if !ok {
-		fmt.Errorf("exp not *ast.Identifier. got = %T", exp)
-		return false
-	}
+		if program == nil {
+			t.Fatalf("ParseProgram() returned nil for %q", input)
+		}
 
-	if ident.Value != value {
-		t.Errorf("ident.Value not %s. got = %s", value, ident.Value)
-		return false
+		if len(program.Statements) != 0 {
+			t.Errorf("program for %q has statements. got=%q", input, program.String())
+		}
 	}
+}
 
-	if ident.TokenLiteral() != value {
-		t.Errorf("ident.TokenLiteral not %s. got = %s", value, ident.TokenLiteral())
-		return false
-	}
+func TestNodeSpans(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b * 2
+};
+add(1, (2 + 3))[0];
+if (x) { y } else if (z) { w }
+"str" |> f`
 
-	return true
-}
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
 
-func testLiteralExpression(
-	t *testing.T,
-	exp ast.Expression,
-	expected interface{},
-) bool {
-	switch v := expected.(type) {
-	case int:
-		return testIntegerLiteral(t, exp, int64(v))
-	case int64:
-		return testIntegerLiteral(t, exp, v)
-	case string:
-		return testIdentifier(t, exp, v)
-	case bool:
-		return testBooleanLiteral(t, exp, v)
-	}
-	t.Errorf("type of exp not handled. got = %T", exp)
-	return false
-}
+	let := program.Statements[0].(*ast.LetStatement)
+	function := let.Value.(*ast.FunctionLiteral)
+	sum := function.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	index := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	call := index.Left.(*ast.CallExpression)
+	ifExp := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	pipe := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
 
-func testInfixExpression(t *testing.T, exp ast.Expression, left interface{},
-	operator string, right interface{}) bool {
-	opExp, ok := exp.(*ast.InfixExpression)
-	if !ok {
-		t.Errorf("exp is not ast.InfixExpression. got = %T(%s)", exp, exp)
-		return false
+	tests := []struct {
+		name     string
+		node     ast.Node
+		expected ast.Span
+	}{
+		{"program", program, span(1, 1, 6, 11)},
+		// a statement includes its semicolon
+		{"let statement", let, span(1, 1, 3, 3)},
+		{"let name", let.Name, span(1, 5, 1, 8)},
+		{"function literal", function, span(1, 11, 3, 2)},
+		{"parameter", function.Parameters[1], span(1, 17, 1, 18)},
+		{"function body", function.Body, span(1, 20, 3, 2)},
+		{"infix expression", sum, span(2, 3, 2, 12)},
+		{"nested infix expression", sum.Right, span(2, 7, 2, 12)},
+		{"expression statement", program.Statements[1], span(4, 1, 4, 20)},
+		{"index expression", index, span(4, 1, 4, 19)},
+		{"call expression", call, span(4, 1, 4, 16)},
+		{"integer literal", call.Arguments[0], span(4, 5, 4, 6)},
+		// the parentheses around an expression aren't part of it
+		{"grouped expression", call.Arguments[1], span(4, 9, 4, 14)},
+		{"if expression", ifExp, span(5, 1, 5, 31)},
+		{"consequence", ifExp.Consequence, span(5, 8, 5, 13)},
+		{"else if", ifExp.Alternative, span(5, 19, 5, 31)},
+		{"else if expression", ifExp.ElseIf(), span(5, 19, 5, 31)},
+		{"string literal", pipe.Left, span(6, 1, 6, 6)},
+		{"pipe", pipe, span(6, 1, 6, 11)},
 	}
 
-	if !testLiteralExpression(t, opExp.Left, left) {
-		return false
+	for _, tt := range tests {
+		if tt.node.Span() != tt.expected {
+			t.Errorf("wrong span for the %s. expected = %+v, got = %+v", tt.name, tt.expected, tt.node.Span())
+		}
 	}
 
-	if opExp.Operator != operator {
-		t.Errorf("exp.Operator is not '%s'. got = %s", operator, opExp.Operator)
-		return false
+	// an empty program has nowhere to be
+	if empty := New(lexer.New("")).ParseProgram(); empty.Span() != (ast.Span{}) {
+		t.Errorf("an empty program should have the zero span. got = %+v", empty.Span())
 	}
+}
 
-	if !testLiteralExpression(t, opExp.Right, right) {
-		return false
+func span(startLine, startColumn, endLine, endColumn int) ast.Span {
+	return ast.Span{
+		Start: ast.Position{Line: startLine, Column: startColumn},
+		End:   ast.Position{Line: endLine, Column: endColumn},
 	}
-
-	return true
 }
 
-func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
-	bo, ok := exp.(*ast.Boolean)
-	if !ok {
-		t.Errorf("exp is not *ast.Boolean. got = %T", exp)
-		return false
+func TestChainedComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 < x < 10", "comparisons can't be chained: did you mean 1 < x && x < 10?"},
+		{"a > b > c", "comparisons can't be chained: did you mean a > b && b > c?"},
+		{"0 < x > 5", "comparisons can't be chained: did you mean 0 < x && x > 5?"},
+		{"(1 < x) < 10", "comparisons can't be chained: did you mean 1 < x && x < 10?"},
+		{"0 < n - 1 < len(xs)", "comparisons can't be chained: did you mean 0 < (n - 1) && (n - 1) < len(xs)?"},
+		// only the first link of a longer chain is reported
+		{"a < b < c < d", "comparisons can't be chained: did you mean a < b && b < c?"},
 	}
 
-	if bo.Value != value {
-		t.Errorf("bo.Value is not %t. got = %t", value, bo.Value)
-		return false
-	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
 
-	if bo.TokenLiteral() != fmt.Sprintf("%t", value) {
-		t.Errorf("bo.TokenLiteral() not %t. got = %s",
-			value, bo.TokenLiteral())
-		return false
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
 	}
 
-	return true
+	// comparing the results of comparisons for equality, or combining them, is fine
+	for _, input := range []string{"1 < x && x < 10", "(a < b) == (c < d)", "a < b == true", "!(a < b)"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
 }
@@ -2,23 +2,94 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/kahvecikaan/monkey-lang/evaluator"
 	"github.com/kahvecikaan/monkey-lang/lexer"
 	"github.com/kahvecikaan/monkey-lang/object"
 	"github.com/kahvecikaan/monkey-lang/parser"
 	"io"
+	"math"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// REPL meta-commands, which start with a colon so they can't be mistaken for Monkey code
+const (
+	// HELP_COMMAND lists the meta-commands and the builtins
+	HELP_COMMAND = ":help"
+	// TRY_COMMAND evaluates the rest of the line and then discards the bindings it made
+	TRY_COMMAND = ":try "
+	// PRETTY_COMMAND followed by on or off switches between multi-line and one-line results
+	PRETTY_COMMAND = ":pretty"
+	// VERBOSE_COMMAND followed by on or off switches showing the type of results in front of them
+	VERBOSE_COMMAND = ":verbose"
+	// SAVE_COMMAND followed by a file name writes the lines evaluated successfully so far to it
+	SAVE_COMMAND = ":save"
+	// DUMP_COMMAND prints every binding made during the session as the statement making it
+	DUMP_COMMAND = ":dump"
+)
+
+// commands describes the meta-commands for :help
+var commands = []struct {
+	usage string
+	doc   string
+}{
+	{":help", "show this help"},
+	{":try CODE", "evaluate CODE, then discard the bindings it made"},
+	{":pretty on|off", "print nested arrays and hashes over several lines"},
+	{":verbose on|off", "print the type of results, and their length if they have one"},
+	{":save FILE", "write the lines evaluated without errors to FILE"},
+	{":dump", "print the bindings made so far as let statements"},
+}
+
+// options are the settings Start is given and the ones changed by meta-commands during a session
+type options struct {
+	prompt string
+	// banner shows the monkey face above parser errors
+	banner  bool
+	color   bool
+	pretty  bool
+	verbose bool
+}
+
+// Option configures the REPL started by Start, for tools embedding it with their own look
+type Option func(*options)
+
+// WithPrompt replaces PROMPT as the text printed before each line is read
+func WithPrompt(prompt string) Option {
+	return func(opts *options) {
+		opts.prompt = prompt
+	}
+}
+
+// WithBanner sets whether the monkey face is printed above parser errors, which it is by default
+func WithBanner(banner bool) Option {
+	return func(opts *options) {
+		opts.banner = banner
+	}
+}
+
+// WithColor sets whether the output uses ANSI colors, which it doesn't by default so output that
+// isn't going to a terminal stays plain
+func WithColor(color bool) Option {
+	return func(opts *options) {
+		opts.color = color
+	}
+}
+
 // ANSI color codes
 const (
 	ColorReset  = "\033[0m"
 	ColorOrange = "\033[38;5;208m"
+	ColorRed    = "\033[31m"
 )
 
-const MONKEY_FACE = ColorOrange + `
+const MONKEY_FACE = ColorOrange + monkeyFace + ColorReset
+
+const monkeyFace = `
             __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
@@ -38,39 +109,299 @@ const MONKEY_FACE = ColorOrange + `
  ██║ ╚═╝ ██║╚██████╔╝██║ ╚████║██║  ██╗███████╗   ██║   
  ╚═╝     ╚═╝ ╚═════╝ ╚═╝  ╚═══╝╚═╝  ╚═╝╚══════╝   ╚═╝   
          SYNTAX ERROR - TIME TO DEBUG!
-` + ColorReset
+`
 
-func Start(in io.Reader, out io.Writer) {
+func Start(in io.Reader, out io.Writer, configure ...Option) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	opts := newOptions(configure)
+	// the lines that evaluated without errors, for :save
+	history := []string{}
 
 	for {
-		fmt.Fprintf(out, PROMPT)
+		io.WriteString(out, opts.prompt)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if strings.TrimSpace(line) == HELP_COMMAND {
+			printHelp(out)
+			continue
+		}
+
+		if strings.TrimSpace(line) == DUMP_COMMAND {
+			dumpEnv(out, env)
+			continue
+		}
+
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == PRETTY_COMMAND {
+			setToggle(out, PRETTY_COMMAND, fields[1:], &opts.pretty)
+			continue
+		}
+
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == VERBOSE_COMMAND {
+			setToggle(out, VERBOSE_COMMAND, fields[1:], &opts.verbose)
+			continue
+		}
+
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == SAVE_COMMAND {
+			saveHistory(out, fields[1:], history)
+			continue
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if strings.HasPrefix(line, TRY_COMMAND) {
+			snapshot := env.Snapshot()
+			evalLine(out, strings.TrimPrefix(line, TRY_COMMAND), env, opts)
+			env.Restore(snapshot)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		if evalLine(out, line, env, opts) {
+			history = append(history, line)
 		}
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, MONKEY_FACE)
+func newOptions(configure []Option) *options {
+	opts := &options{prompt: PROMPT, banner: true}
+	for _, option := range configure {
+		option(opts)
+	}
+
+	return opts
+}
+
+func saveHistory(out io.Writer, args []string, history []string) {
+	if len(args) != 1 {
+		io.WriteString(out, "usage: "+SAVE_COMMAND+" FILE\n")
+		return
+	}
+
+	var content strings.Builder
+	for _, line := range history {
+		content.WriteString(line + "\n")
+	}
+
+	if err := os.WriteFile(args[0], []byte(content.String()), 0644); err != nil {
+		fmt.Fprintf(out, "could not save: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(out, "saved %d lines to %s\n", len(history), args[0])
+}
+
+// setToggle sets an on|off option from the arguments of its command
+func setToggle(out io.Writer, command string, args []string, option *bool) {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		io.WriteString(out, "usage: "+command+" on|off\n")
+		return
+	}
+
+	*option = args[0] == "on"
+}
+
+// evalLine evaluates a line and prints its result, reporting whether it had something to evaluate
+// and did so without errors
+func evalLine(out io.Writer, line string, env *object.Environment, opts *options) bool {
+	l := lexer.New(line)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, p.Errors(), opts)
+		return false
+	}
+
+	// blank and comment-only lines have nothing to show
+	if len(program.Statements) == 0 {
+		return false
+	}
+
+	// NULL is what statements without a value evaluate to, like calls to puts, so it isn't
+	// echoed; a null produced by lookups can still be seen with puts
+	evaluated := evaluator.Eval(program, env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		printRuntimeError(out, errObj, opts)
+		return false
+	}
+	if evaluated != nil && evaluated != object.NULL {
+		inspected, errObj := inspect(evaluated, opts)
+		if errObj != nil {
+			printRuntimeError(out, errObj, opts)
+			return false
+		}
+		io.WriteString(out, inspected+"\n")
+	}
+
+	return true
+}
+
+// dumpEnv prints the bindings of env as Monkey source reloading them, one statement per line with
+// the enums first, since values further down may be members of them. The bindings whose values
+// have no literal form, such as functions, are printed in a comment.
+func dumpEnv(out io.Writer, env *object.Environment) {
+	bindings := env.Bindings()
+	for _, binding := range bindings {
+		if enum, ok := binding.Value.(*object.Enum); ok && enum.Name == binding.Name {
+			io.WriteString(out, enum.Inspect()+";\n")
+		}
+	}
+
+	for _, binding := range bindings {
+		if enum, ok := binding.Value.(*object.Enum); ok && enum.Name == binding.Name {
+			continue
+		}
+
+		if src, ok := source(binding.Value, map[object.Object]bool{}); ok {
+			io.WriteString(out, "let "+binding.Name+" = "+src+";\n")
+			continue
+		}
+
+		value := strings.Join(strings.Fields(binding.Value.Inspect()), " ")
+		io.WriteString(out, "// not reloadable: let "+binding.Name+" = "+value+";\n")
+	}
+}
+
+// source returns the literal evaluating to a value equal to obj, or false when there's none:
+// functions, builtins, generators and enums, strings holding a quote, which the lexer has no
+// escape for, floats that aren't finite and collections holding any of those or themselves
+func source(obj object.Object, seen map[object.Object]bool) (string, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.Boolean, *object.Null, *object.EnumValue:
+		return obj.Inspect(), true
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return "", false
+		}
+		return obj.Inspect(), true
+	case *object.BigInt:
+		return `bigint("` + obj.Inspect() + `")`, true
+	case *object.String:
+		if strings.Contains(obj.Value, `"`) {
+			return "", false
+		}
+		return `"` + obj.Value + `"`, true
+	}
+
+	if seen[obj] {
+		return "", false
+	}
+	seen[obj] = true
+	defer delete(seen, obj)
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements, ok := sources(obj.Elements, seen)
+		return "[" + strings.Join(elements, ", ") + "]", ok
+	case *object.Set:
+		members, ok := sources(obj.Members(), seen)
+		return "set([" + strings.Join(members, ", ") + "])", ok
+	case *object.Hash:
+		pairs := []string{}
+		for _, pair := range obj.SortedPairs() {
+			key, ok := source(pair.Key, seen)
+			if !ok {
+				return "", false
+			}
+			value, ok := source(pair.Value, seen)
+			if !ok {
+				return "", false
+			}
+			pairs = append(pairs, key+": "+value)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}", true
+	}
+
+	return "", false
+}
+
+func sources(objs []object.Object, seen map[object.Object]bool) ([]string, bool) {
+	srcs := []string{}
+	for _, obj := range objs {
+		src, ok := source(obj, seen)
+		if !ok {
+			return nil, false
+		}
+		srcs = append(srcs, src)
+	}
+
+	return srcs, true
+}
+
+// inspect formats a value the way the REPL shows results, which is how puts prints it. In verbose
+// mode the value is preceded by its type, and by its length for strings and collections, e.g.
+// "ARRAY(3): [1, 2, 3]". The error is the one a "__str__" function of a hash in obj failed with.
+func inspect(obj object.Object, opts *options) (string, *object.Error) {
+	render := object.InspectWith
+	if opts.pretty {
+		render = object.PrettyInspectWith
+	}
+	inspected, errObj := evaluator.Stringify(context.Background(), obj, render)
+	if errObj != nil {
+		return "", errObj
+	}
+	if !opts.verbose {
+		return inspected, nil
+	}
+
+	if length, ok := objectLen(obj); ok {
+		return fmt.Sprintf("%s(%d): %s", obj.Type(), length, inspected), nil
+	}
+
+	return string(obj.Type()) + ": " + inspected, nil
+}
+
+// objectLen returns what len or size reports for obj, the number of pairs for a hash
+func objectLen(obj object.Object) (int, bool) {
+	switch obj := obj.(type) {
+	case *object.String:
+		return len(obj.Value), true
+	case *object.Array:
+		return len(obj.Elements), true
+	case *object.Hash:
+		length := 0
+		for _, chain := range obj.Pairs {
+			length += len(chain)
+		}
+		return length, true
+	case *object.Set:
+		return obj.Len(), true
+	default:
+		return 0, false
+	}
+}
+
+func printHelp(out io.Writer) {
+	io.WriteString(out, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.usage, cmd.doc)
+	}
+
+	io.WriteString(out, "Builtins:\n")
+	for _, builtin := range evaluator.Builtins() {
+		io.WriteString(out, "  "+evaluator.BuiltinDoc(builtin)+"\n")
+	}
+}
+
+// colored wraps s in the ANSI color code when colors are on
+func colored(s string, color string, opts *options) string {
+	if !opts.color {
+		return s
+	}
+
+	return color + s + ColorReset
+}
+
+func printRuntimeError(out io.Writer, errObj *object.Error, opts *options) {
+	io.WriteString(out, colored("Runtime error: "+errObj.Error(), ColorRed, opts)+"\n")
+}
+
+func printParserErrors(out io.Writer, errors []string, opts *options) {
+	if opts.banner {
+		io.WriteString(out, colored(monkeyFace, ColorOrange, opts))
+	}
 	io.WriteString(out, "Whoops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
 	for _, msg := range errors {
@@ -0,0 +1,177 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/kahvecikaan/monkey-lang/evaluator"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run feeds input to a REPL and returns everything it printed. Unless opts say otherwise, there's
+// no prompt, banner or color to get in the way of the output.
+func run(input string, opts ...Option) string {
+	var out bytes.Buffer
+	opts = append([]Option{WithPrompt(""), WithBanner(false), WithColor(false)}, opts...)
+	Start(strings.NewReader(input), &out, opts...)
+
+	return out.String()
+}
+
+func TestMetaCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"pretty",
+			":pretty on\n[[1, 2], {\"a\": 1}]\n:pretty off\n[[1, 2]]\n",
+			"[\n  [1, 2],\n  {a: 1}\n]\n[[1, 2]]\n",
+		},
+		{"pretty usage", ":pretty\n:pretty maybe\n", "usage: :pretty on|off\nusage: :pretty on|off\n"},
+		{
+			"verbose",
+			":verbose on\n42\n[1, 2, 3]\n\"ab\"\n{1: 2}\nset([1])\n:verbose off\n42\n",
+			"INTEGER: 42\nARRAY(3): [1, 2, 3]\nSTRING(2): ab\nHASH(1): {1: 2}\nSET(1): set([1])\n42\n",
+		},
+		{"verbose usage", ":verbose yes\n", "usage: :verbose on|off\n"},
+		{
+			"echo with __str__",
+			"let p = {\"__str__\": fn(self) { \"point\" }};\np\n[p, {\"at\": p}]\n:pretty on\n[[1], p]\n",
+			"point\n[point, {at: point}]\n[\n  [1],\n  point\n]\n",
+		},
+		{
+			"echo with a failing __str__",
+			"{\"__str__\": fn(self) { 1 }}\n",
+			"Runtime error: `__str__` must return STRING, got INTEGER\n",
+		},
+		{"dump", "let b = 2;\nlet a = [1];\n:dump\n", "let a = [1];\nlet b = 2;\n"},
+		{"dump after try", "let a = 1;\n:try let b = 2;\n:dump\n", "let a = 1;\n"},
+		{
+			"dump literals",
+			"let a = [\"x\", 1.5, null, {\"k\": set([true])}];\nlet b = bigint(2) * bigint(9223372036854775807);\n:dump\n",
+			"let a = [\"x\", 1.5, null, {\"k\": set([true])}];\nlet b = bigint(\"18446744073709551614\");\n",
+		},
+		{
+			"dump enums",
+			"enum Color { Red, Green }\nlet c = Color.Green;\n:dump\n",
+			"enum Color { Red, Green };\nlet c = Color.Green;\n",
+		},
+		{
+			"dump without literals",
+			"let f = fn(x) { x };\nlet l = len;\nlet g = generator(f, 1);\nlet q = [base64Decode(\"Ig==\")];\n:dump\n",
+			"// not reloadable: let f = fn f(x) { x };\n// not reloadable: let g = generator;\n" +
+				"// not reloadable: let l = builtin function;\n// not reloadable: let q = [\"];\n",
+		},
+		{"save usage", ":save\n", "usage: :save FILE\n"},
+	}
+
+	for _, tt := range tests {
+		if output := run(tt.input); output != tt.expected {
+			t.Errorf("%s: wrong output. expected=%q, got=%q", tt.name, tt.expected, output)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	output := run(":help\n")
+
+	for _, cmd := range commands {
+		if !strings.Contains(output, cmd.usage) {
+			t.Errorf("help doesn't list %s. got=%q", cmd.usage, output)
+		}
+	}
+
+	builtins := output[strings.Index(output, "Builtins:\n"):]
+	for _, builtin := range evaluator.Builtins() {
+		if !strings.Contains(builtins, "  "+evaluator.BuiltinDoc(builtin)+"\n") {
+			t.Errorf("help doesn't document %s", builtin.Name)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.monkey")
+
+	// meta-commands, lines that fail, blank lines and lines tried out aren't saved
+	input := strings.Join([]string{
+		"let a = 1;",
+		":help",
+		"let b = ;",
+		"a + true",
+		":try let c = 2;",
+		"",
+		":pretty on",
+		"let d = [a];",
+		":save " + path,
+	}, "\n") + "\n"
+
+	output := run(input)
+	if !strings.HasSuffix(output, "saved 2 lines to "+path+"\n") {
+		t.Errorf("wrong output for :save. got=%q", output)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != "let a = 1;\nlet d = [a];\n" {
+		t.Errorf("wrong lines saved. got=%q", saved)
+	}
+
+	output = run(":save " + filepath.Join(path, "session.monkey") + "\n")
+	if !strings.HasPrefix(output, "could not save: ") {
+		t.Errorf("no error for an unwritable file. got=%q", output)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	message := "Runtime error: at line 1, col 3: type mismatch: INTEGER + BOOLEAN"
+
+	if output := run("1 + true\n"); output != message+"\n" {
+		t.Errorf("wrong output without color. expected=%q, got=%q", message+"\n", output)
+	}
+
+	expected := ColorRed + message + ColorReset + "\n"
+	if output := run("1 + true\n", WithColor(true)); output != expected {
+		t.Errorf("wrong output with color. expected=%q, got=%q", expected, output)
+	}
+
+	// parser errors aren't runtime errors
+	if output := run("let a = ;\n", WithColor(true)); strings.Contains(output, "Runtime error") {
+		t.Errorf("parser error reported as a runtime error. got=%q", output)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	parserError := "Whoops! We ran into some monkey business here!\n parser errors:\n" +
+		"\tno prefix parse function for ; found\n"
+
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		// the defaults: PROMPT, the banner and no color
+		{"defaults", "1\n1 + true\n", nil,
+			PROMPT + "1\n" + PROMPT + "Runtime error: at line 1, col 3: type mismatch: INTEGER + BOOLEAN\n" + PROMPT},
+		{"default banner", "let a = ;\n", nil, PROMPT + monkeyFace + parserError + PROMPT},
+		{"prompt", "1\n2\n", []Option{WithPrompt("monkey> ")}, "monkey> 1\nmonkey> 2\nmonkey> "},
+		{"no banner", "let a = ;\n", []Option{WithBanner(false)}, PROMPT + parserError + PROMPT},
+		{"color", "let a = ;\n", []Option{WithColor(true)}, PROMPT + MONKEY_FACE + parserError + PROMPT},
+		{"color off", "1 + true\n", []Option{WithColor(true), WithColor(false)},
+			PROMPT + "Runtime error: at line 1, col 3: type mismatch: INTEGER + BOOLEAN\n" + PROMPT},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out, tt.opts...)
+
+		if out.String() != tt.expected {
+			t.Errorf("%s: wrong output. expected=%q, got=%q", tt.name, tt.expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package resolver finds unbound identifiers in a program without running it. It's a library for
+// hosts and tools that want the check: interp.Run, the REPL and the monkey command don't resolve
+// programs before evaluating them, since a name that's only missing in a branch that never runs
+// isn't an error there.
+package resolver
+
+import (
+	"fmt"
+	"github.com/kahvecikaan/monkey-lang/ast"
+)
+
+// scope mirrors an evaluator environment: the program, every function call, every do block and
+// every branch of an if get their own
+type scope struct {
+	names map[string]bool
+	outer *scope
+
+	// function literals defined in this scope, whose bodies are resolved once the scope is complete
+	functions []*ast.FunctionLiteral
+	// block scopes nested in this one, whose functions wait for this scope as well
+	blocks []*scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: make(map[string]bool), outer: outer}
+}
+
+// declare binds name in s, except for _, which the evaluator never binds
+func (s *scope) declare(name *ast.Identifier) {
+	if !name.IsBlank() {
+		s.names[name.Value] = true
+	}
+}
+
+func (s *scope) isBound(name string) bool {
+	if s.names[name] {
+		return true
+	}
+	if s.outer != nil {
+		return s.outer.isBound(name)
+	}
+
+	return false
+}
+
+// Resolver statically reports identifiers that would fail with "identifier not found" at runtime,
+// including those in branches and functions that never execute.
+//
+// A function body is resolved after the scope defining the function is complete, since it can't
+// run before the function exists. That makes recursion and top-level functions calling functions
+// defined further down the program work. The flip side is that a function called before a name
+// it uses is bound isn't reported.
+type Resolver struct {
+	errors    []string
+	isDefined func(name string) bool
+}
+
+// New returns a Resolver treating the names isDefined reports as bound, e.g. builtins or the
+// bindings already present in an environment. isDefined may be nil.
+func New(isDefined func(name string) bool) *Resolver {
+	if isDefined == nil {
+		isDefined = func(string) bool { return false }
+	}
+
+	return &Resolver{errors: []string{}, isDefined: isDefined}
+}
+
+func (r *Resolver) Errors() []string {
+	return r.errors
+}
+
+func (r *Resolver) Resolve(program *ast.Program) {
+	w := &walker{unbound: r.resolveIdentifier}
+	global := newScope(nil)
+
+	w.walk(program, global)
+	w.walkFunctions(global)
+}
+
+func (r *Resolver) resolveIdentifier(ident *ast.Identifier) {
+	if !r.isDefined(ident.Value) {
+		r.errors = append(r.errors, fmt.Sprintf("identifier not found: %s", ident.Value))
+	}
+}
+
+// FreeIdentifiers returns the identifiers the body of a function with params looks up without
+// binding them itself, scoped the way Resolver scopes them. The evaluator looks them up in the
+// environment the function was defined in.
+func FreeIdentifiers(params []*ast.Identifier, body *ast.BlockStatement) []*ast.Identifier {
+	free := []*ast.Identifier{}
+	w := &walker{unbound: func(ident *ast.Identifier) { free = append(free, ident) }}
+
+	fnScope := newScope(nil)
+	for _, param := range params {
+		fnScope.declare(param)
+	}
+	w.walk(body, fnScope)
+	w.walkFunctions(fnScope)
+
+	return free
+}
+
+// walker walks a node through the scopes it runs in, calling unbound for every identifier looked
+// up that none of them binds
+type walker struct {
+	unbound func(ident *ast.Identifier)
+}
+
+func (w *walker) walk(node ast.Node, s *scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			w.walk(node.Value, s)
+			s.declare(node.Name)
+			return false
+		case *ast.EnumStatement:
+			s.declare(node.Name)
+			return false
+		case *ast.GuardStatement:
+			w.walk(node.Condition, s)
+			w.walkBlock(node.Alternative, s)
+			return false
+		case *ast.IfExpression:
+			w.walk(node.Condition, s)
+			w.walkBlock(node.Consequence, s)
+			if node.Alternative != nil {
+				w.walkBlock(node.Alternative, s)
+			}
+			return false
+		case *ast.DoExpression:
+			w.walkBlock(node.Body, s)
+			return false
+		case *ast.WithExpression:
+			// each value sees the names bound before it, and the block sees them all
+			withScope := newScope(s)
+			for i, name := range node.Names {
+				w.walk(node.Values[i], withScope)
+				withScope.declare(name)
+			}
+			w.walk(node.Body, withScope)
+			s.blocks = append(s.blocks, withScope)
+			return false
+		case *ast.FunctionLiteral:
+			s.functions = append(s.functions, node)
+			return false
+		case *ast.MemberExpression:
+			// members are only known once the object is evaluated
+			w.walk(node.Object, s)
+			return false
+		case *ast.Identifier:
+			if !s.isBound(node.Value) {
+				w.unbound(node)
+			}
+		}
+
+		return true
+	})
+}
+
+// walkBlock walks a block that gets an environment of its own inside s
+func (w *walker) walkBlock(block *ast.BlockStatement, s *scope) {
+	blockScope := newScope(s)
+	w.walk(block, blockScope)
+	s.blocks = append(s.blocks, blockScope)
+}
+
+// walkFunctions walks the bodies of the functions defined in s and in the blocks nested in it,
+// each in a scope of its own holding the parameters
+func (w *walker) walkFunctions(s *scope) {
+	// walking a body can't add functions to s, only to the body's own scope
+	for _, fn := range s.functions {
+		fnScope := newScope(s)
+		for _, param := range fn.Parameters {
+			fnScope.declare(param)
+		}
+
+		w.walk(fn.Body, fnScope)
+		w.walkFunctions(fnScope)
+	}
+	s.functions = nil
+
+	for _, block := range s.blocks {
+		w.walkFunctions(block)
+	}
+	s.blocks = nil
+}
@@ -0,0 +1,153 @@
+package resolver_test
+
+import (
+	"github.com/kahvecikaan/monkey-lang/ast"
+	"github.com/kahvecikaan/monkey-lang/evaluator"
+	"github.com/kahvecikaan/monkey-lang/lexer"
+	"github.com/kahvecikaan/monkey-lang/parser"
+	"github.com/kahvecikaan/monkey-lang/resolver"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; a;", []string{}},
+		{"a;", []string{"identifier not found: a"}},
+		{"a; let a = 1;", []string{"identifier not found: a"}},
+		{"let a = a;", []string{"identifier not found: a"}},
+		{"len([1, 2]); puts(1);", []string{}},
+		{"let f = fn(x, y) { x + y }; f(1, 2);", []string{}},
+		{"let f = fn(x) { x + z };", []string{"identifier not found: z"}},
+		{"let f = fn(x) { let y = x; y };", []string{}},
+		{"let f = fn(x) { let y = x; y }; y;", []string{"identifier not found: y"}},
+		{"let f = fn(x) { x }; x;", []string{"identifier not found: x"}},
+		{
+			// closures see the parameters of the functions they're defined in
+			"let adder = fn(x) { fn(y) { x + y } };",
+			[]string{},
+		},
+		{
+			// recursion
+			"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };",
+			[]string{},
+		},
+		{
+			// forward references to functions defined later at the top level
+			"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };",
+			[]string{},
+		},
+		{
+			// branches are checked even though they never run
+			"if (false) { missing } else { 1 };",
+			[]string{"identifier not found: missing"},
+		},
+		{"if (x) { 1 };", []string{"identifier not found: x"}},
+		{"[1, a, {b: c}][d];", []string{
+			"identifier not found: a",
+			"identifier not found: b",
+			"identifier not found: c",
+			"identifier not found: d",
+		}},
+		{"-a + !b;", []string{"identifier not found: a", "identifier not found: b"}},
+		{"1 |> double;", []string{"identifier not found: double"}},
+		{"[1].len(); [1].width();", []string{"identifier not found: width"}},
+		{"let f = fn() { return g; };", []string{"identifier not found: g"}},
+		{"enum Color { Red }; Color.Red;", []string{}},
+		{"Color.Red; enum Color { Red };", []string{"identifier not found: Color"}},
+		{"let x = do { let t = 1; t * 2 }; x;", []string{}},
+		{"let x = do { let t = 1; t }; t;", []string{"identifier not found: t"}},
+		{"let a = 1; do { a + b };", []string{"identifier not found: b"}},
+		{"with (x = 5, y = x * 2) { let z = 1; x + y + z };", []string{}},
+		{"with (x = y, y = 1) { y };", []string{"identifier not found: y"}},
+		{"with (x = 5) { x }; x;", []string{"identifier not found: x"}},
+		{"let f = fn(x) { guard (x) else { let t = y; t }; t };", []string{
+			"identifier not found: y",
+			"identifier not found: t",
+		}},
+		{"with (g = fn() { later() }) { g };", []string{"identifier not found: later"}},
+		{
+			// functions in a do block see names bound later in the enclosing scope too
+			"let g = do { fn() { later() } }; let later = fn() { 1 };",
+			[]string{},
+		},
+		{"if (true) { let t = 1; t };", []string{}},
+		{"if (true) { let t = 1 }; t;", []string{"identifier not found: t"}},
+		{"if (false) { 1 } else { let t = 1 }; t;", []string{"identifier not found: t"}},
+		{"if (false) { let t = 1 } else { t };", []string{"identifier not found: t"}},
+		{"let a = 1; if (true) { let b = a; if (true) { a + b } };", []string{}},
+		{"let f = fn() { if (true) { let t = 1 }; t };", []string{"identifier not found: t"}},
+		{
+			// functions in a branch see the branch's bindings
+			"if (true) { let t = 1; let g = fn() { t } };",
+			[]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %q", tt.input, p.Errors())
+		}
+
+		r := resolver.New(evaluator.IsBuiltin)
+		r.Resolve(program)
+
+		errors := r.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q",
+				tt.input, tt.expected, errors)
+			continue
+		}
+
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("wrong error %d for %q. expected=%q, got=%q",
+					i, tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestResolveWithDefinedNames(t *testing.T) {
+	input := "let c = a + b; c + d;"
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	defined := map[string]bool{"a": true, "b": true}
+	r := resolver.New(func(name string) bool { return defined[name] })
+	r.Resolve(program)
+
+	errors := r.Errors()
+	if len(errors) != 1 || errors[0] != "identifier not found: d" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+
+	r = resolver.New(nil)
+	r.Resolve(program)
+	if len(r.Errors()) != 3 {
+		t.Errorf("wrong number of errors without defined names. got=%q", r.Errors())
+	}
+}
+
+func TestResolveBlankNames(t *testing.T) {
+	// _ can't be written as a value, so the use of it is built by hand
+	program := parser.New(lexer.New("let _ = 1; let f = fn(_) { 1 }; with (_ = 2) { 3 };")).ParseProgram()
+	blankUse := &ast.ExpressionStatement{Expression: &ast.Identifier{Value: "_"}}
+	program.Statements = append(program.Statements, blankUse)
+	// and in the with block, where _ isn't bound either
+	with := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.WithExpression)
+	with.Body.Statements = append(with.Body.Statements, blankUse)
+
+	r := resolver.New(nil)
+	r.Resolve(program)
+
+	errors := r.Errors()
+	if len(errors) != 2 || errors[0] != "identifier not found: _" || errors[1] != "identifier not found: _" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/kahvecikaan/monkey-lang/evaluator"
+	"github.com/kahvecikaan/monkey-lang/interp"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"io"
+)
+
+// runSource evaluates src as a whole program, for `monkey -e` and for programs piped in. With echo
+// the value of its last statement is printed to out the way the REPL does, so nothing for a null.
+// Parser and runtime errors are printed to errOut. It returns the exit code for the process: 0 on
+// success, 1 when there were errors.
+func runSource(src string, echo bool, out, errOut io.Writer) int {
+	result, errs := interp.Run(src, nil)
+	if len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Fprintln(errOut, err)
+		}
+		return 1
+	}
+
+	if echo && result != nil && result != object.NULL {
+		inspected, errObj := evaluator.Stringify(context.Background(), result, object.InspectWith)
+		if errObj != nil {
+			fmt.Fprintln(errOut, errObj.Error())
+			return 1
+		}
+		fmt.Fprintln(out, inspected)
+	}
+
+	return 0
+}
+
+// runProfiled runs the Monkey file at path like a program piped in, and then prints to errOut
+// how many times each function was called and how many nodes were evaluated in it, for
+// `monkey --profile`. The report is printed even when evaluating the program fails.
+func runProfiled(path string, out, errOut io.Writer) int {
+	program, errs, err := interp.ParseFile(path)
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	if len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Fprintln(errOut, err)
+		}
+		return 1
+	}
+
+	code := 0
+	profile := evaluator.NewProfile()
+	ctx := evaluator.WithProfile(context.Background(), profile)
+	if errObj, ok := evaluator.EvalContext(ctx, program, object.NewEnvironment()).(*object.Error); ok {
+		fmt.Fprintln(errOut, errObj)
+		code = 1
+	}
+	fmt.Fprint(errOut, profile.Report())
+
+	return code
+}
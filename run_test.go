@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRunSource(t *testing.T) {
+	tests := []struct {
+		src    string
+		echo   bool
+		code   int
+		out    string
+		errOut string
+	}{
+		{"let double = fn(x) { x * 2 }; double(21)", true, 0, "42\n", ""},
+		{`"hello"`, true, 0, "hello\n", ""},
+		// like the REPL, nothing is printed for a null or a statement without a value
+		{"let a = 1;", true, 0, "", ""},
+		{"if (false) { 1 }", true, 0, "", ""},
+		{"let a = ;", true, 1, "", "no prefix parse function for ; found\n"},
+		{"let = 1; let x 2;", true, 1, "",
+			"expected next token to be IDENT, got =\nexpected next token to be =, got INT\n"},
+		{"1 + true", true, 1, "", "at line 1, col 3: type mismatch: INTEGER + BOOLEAN\n"},
+		{`[{"__str__": fn(self) { "p" }}]`, true, 0, "[p]\n", ""},
+		// a program piped in runs without echoing the value of its last statement
+		{"let double = fn(x) { x * 2 }; double(21)", false, 0, "", ""},
+		{"1 + true", false, 1, "", "at line 1, col 3: type mismatch: INTEGER + BOOLEAN\n"},
+	}
+
+	for _, tt := range tests {
+		var out, errOut bytes.Buffer
+		code := runSource(tt.src, tt.echo, &out, &errOut)
+
+		if code != tt.code {
+			t.Errorf("wrong exit code for %q. expected=%d, got=%d", tt.src, tt.code, code)
+		}
+		if out.String() != tt.out {
+			t.Errorf("wrong output for %q. expected=%q, got=%q", tt.src, tt.out, out.String())
+		}
+		if errOut.String() != tt.errOut {
+			t.Errorf("wrong error output for %q. expected=%q, got=%q", tt.src, tt.errOut, errOut.String())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"github.com/kahvecikaan/monkey-lang/ast"
+	"github.com/kahvecikaan/monkey-lang/evaluator"
+	"github.com/kahvecikaan/monkey-lang/interp"
+	"github.com/kahvecikaan/monkey-lang/object"
+	"io"
+	"strings"
+)
+
+const testPrefix = "test_"
+
+// runTests runs the Monkey file at path and then calls every function it binds with a top-level
+// `let test_... = fn() {...}`, in the order they're defined. A test fails when calling it
+// evaluates to an error, e.g. from a failed assert, or when the program returned before defining
+// it. It returns the exit code for the process: 0 when every test passed, 1 otherwise.
+func runTests(path string, out io.Writer) int {
+	program, errs, err := interp.ParseFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "%s\n", err)
+		return 1
+	}
+	if len(errs) != 0 {
+		fmt.Fprintf(out, "%s: parser errors:\n", path)
+		for _, err := range errs {
+			fmt.Fprintf(out, "\t%s\n", err)
+		}
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	if result, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		fmt.Fprintf(out, "%s: %s\n", path, result.Error())
+		return 1
+	}
+
+	passed, failed := 0, 0
+	for _, name := range testNames(program) {
+		fn, ok := env.Get(name)
+		if !ok {
+			// a top-level return ended the program before this let was reached
+			fmt.Fprintf(out, "FAIL %s: not run, the program returned before defining it\n", name)
+			failed++
+			continue
+		}
+		if fn.Type() != object.FUNCTION_OBJ {
+			continue
+		}
+
+		if result, ok := callTest(fn).(*object.Error); ok {
+			fmt.Fprintf(out, "FAIL %s: %s\n", name, result.Error())
+			failed++
+		} else {
+			fmt.Fprintf(out, "PASS %s\n", name)
+			passed++
+		}
+	}
+
+	fmt.Fprintf(out, "%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// callTest calls a test function, turning a panic in the evaluator into an "internal error" the
+// way evalProgram does for a program, so one broken test doesn't stop the others from running
+func callTest(fn object.Object) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = &object.Error{Message: fmt.Sprintf("internal error: %v", r)}
+		}
+	}()
+
+	return evaluator.CallFunction(fn)
+}
+
+// testNames returns the test names bound by the program's top-level let statements, each once
+func testNames(program *ast.Program) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, testPrefix) || seen[let.Name.Value] {
+			continue
+		}
+
+		seen[let.Name.Value] = true
+		names = append(names, let.Name.Value)
+	}
+
+	return names
+}
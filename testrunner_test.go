@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTests(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		code     int
+		expected string // with PATH standing for the file's path
+	}{
+		{
+			"pass",
+			"let test_a = fn() { assert(true) }; let test_b = fn() { 1 };",
+			0,
+			"PASS test_a\nPASS test_b\n2 passed, 0 failed\n",
+		},
+		{
+			"fail",
+			"let test_a = fn() { assertEq(1, 2) };\nlet test_b = fn() { 1 };",
+			1,
+			"FAIL test_a: at line 1, col 21: assertion failed: 1 != 2\nPASS test_b\n1 passed, 1 failed\n",
+		},
+		{
+			"parse error",
+			"let test_a = ;",
+			1,
+			"PATH: parser errors:\n\tno prefix parse function for ; found\n",
+		},
+		{
+			"unreached",
+			"let test_a = fn() { 1 }; return 1; let test_b = fn() { 2 };",
+			1,
+			"PASS test_a\nFAIL test_b: not run, the program returned before defining it\n1 passed, 1 failed\n",
+		},
+		{
+			"not a function",
+			"let test_a = 1; let test_b = fn() { 2 };",
+			0,
+			"PASS test_b\n1 passed, 0 failed\n",
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "test.mk")
+		if err := os.WriteFile(path, []byte(tt.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		code := runTests(path, &out)
+
+		if code != tt.code {
+			t.Errorf("%s: wrong exit code. expected=%d, got=%d", tt.name, tt.code, code)
+		}
+		expected := strings.ReplaceAll(tt.expected, "PATH", path)
+		if out.String() != expected {
+			t.Errorf("%s: wrong output. expected=%q, got=%q", tt.name, expected, out.String())
+		}
+	}
+}
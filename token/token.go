@@ -5,6 +5,11 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line of the token's first character
+	Column  int // 1-based column of the token's first character
+	// EndLine and EndColumn are just past the token's last character, where the next one could start
+	EndLine   int
+	EndColumn int
 }
 
 const (
@@ -14,6 +19,7 @@ const (
 	// Identifiers + literals
 	IDENT  = "IDENT"  // add, foobar, x, y, ...
 	INT    = "INT"    // 12495
+	FLOAT  = "FLOAT"  // 1.5, 1e10, 2.5e-3
 	STRING = "STRING" // "foobar"
 
 	// Operators
@@ -23,6 +29,7 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
 	LT = "<"
 	GT = ">"
@@ -33,8 +40,11 @@ const (
 	AND = "&&"
 	OR  = "||"
 
+	PIPE = "|>"
+
 	// Delimiters
 	COMMA     = ","
+	DOT       = "."
 	COLON     = ":"
 	SEMICOLON = ";"
 
@@ -50,9 +60,16 @@ const (
 	LET      = "LET"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
+	ELIF     = "ELIF"
 	RETURN   = "RETURN"
+	DO       = "DO"
+	ENUM     = "ENUM"
+	YIELD    = "YIELD"
+	WITH     = "WITH"
+	GUARD    = "GUARD"
 )
 
 var keywords = map[string]TokenType{
@@ -60,9 +77,16 @@ var keywords = map[string]TokenType{
 	"let":    LET,
 	"true":   TRUE,
 	"false":  FALSE,
+	"null":   NULL,
 	"if":     IF,
 	"else":   ELSE,
+	"elif":   ELIF,
 	"return": RETURN,
+	"do":     DO,
+	"enum":   ENUM,
+	"yield":  YIELD,
+	"with":   WITH,
+	"guard":  GUARD,
 }
 
 func LookUpIdent(ident string) TokenType {